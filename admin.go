@@ -0,0 +1,599 @@
+package vanity
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adminModulesPath is the path of the modules of the admin API. A module is
+// managed at the path followed by the module path, such as
+// /modules/cmd/tcpproxy.
+const adminModulesPath = "/modules"
+
+// Admin is an HTTP API managing the modules of the configuration file of a
+// Reloader at runtime. Changes are validated, written atomically to the
+// configuration file and served immediately by the Reloader. Requests are
+// authenticated with a bearer token, which may be limited to a module path
+// prefix. Admin is meant to be served on a separate listener or mounted
+// under a path prefix with http.StripPrefix().
+//
+//	GET    /modules         list the modules
+//	GET    /modules/<path>  get a module
+//	PUT    /modules/<path>  add or replace a module
+//	PATCH  /modules/<path>  update the given fields of a module, such as
+//	                        {"state": "deprecated"}
+//	DELETE /modules/<path>  remove a module
+//
+// With a configuration history, tokens without a prefix may also manage the
+// revisions of the configuration. See Reloader.SetHistory().
+//
+//	GET  /revisions                list the revisions without configurations
+//	GET  /revisions/<id>           get a revision
+//	GET  /diff?from=<id>&to=<id>   unified diff of the configurations of two
+//	                               revisions; to defaults to the latest
+//	POST /revisions/<id>/rollback  restore the configuration of a revision
+//
+// Modules are JSON objects like in the configuration file. Errors are JSON
+// objects with an error field.
+type Admin struct {
+	r      *Reloader
+	tokens []AdminToken
+	mux    *http.ServeMux
+
+	auditMu sync.Mutex
+	audit   io.Writer
+}
+
+// AdminToken is a bearer token of the admin API.
+type AdminToken struct {
+	// Name identifies the holder of the token in the audit log, such as a
+	// team.
+	Name string `json:"name"`
+	// Token is the secret bearer token.
+	Token string `json:"token"`
+	// Prefix limits the token to the modules under a path prefix, such as
+	// /payments for /payments and /payments/ledger. Empty prefix grants
+	// access to all modules. Tokens limited to a prefix may not set the
+	// local paths of modules, sourceDir and repoDir, which could point
+	// anywhere on the server.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// scoped reports whether t is limited to a prefix.
+func (t *AdminToken) scoped() bool {
+	return strings.Trim(t.Prefix, "/") != ""
+}
+
+// allows reports whether t may manage the module path. The path is cleaned
+// first, so that dot segments can't escape the prefix.
+func (t *AdminToken) allows(modulePath string) bool {
+	if !t.scoped() {
+		return true
+	}
+	prefix := strings.Trim(t.Prefix, "/")
+	prefix = "/" + prefix
+	modulePath = path.Clean(modulePath)
+	return modulePath == prefix || strings.HasPrefix(modulePath, prefix+"/")
+}
+
+// AuditEntry is a change of the modules recorded in the audit log of the
+// admin API. Denied and failed changes are recorded too.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Token is the name of the token of the request, or empty if the request
+	// was not authenticated.
+	Token  string `json:"token"`
+	Method string `json:"method"`
+	// Path is the module path.
+	Path string `json:"path"`
+	// Status is the response status code.
+	Status int `json:"status"`
+	// Before and After are the module before and after the change.
+	Before *Module `json:"before,omitempty"`
+	After  *Module `json:"after,omitempty"`
+	// Revision is the ID of the revision restored by a rollback.
+	Revision int    `json:"revision,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// adminError is the JSON response of a failed admin API request.
+type adminError struct {
+	Error string `json:"error"`
+}
+
+// errNotFound is returned by module changes of unknown modules.
+var errNotFound = errors.New("vanity: module not found")
+
+// errLocalPath is returned by changes of the local paths of modules by
+// tokens limited to a prefix.
+var errLocalPath = errors.New("vanity: token limited to a prefix may not set local paths")
+
+// errNoHistory is returned by revision requests without a history.
+var errNoHistory = errors.New("vanity: no configuration history")
+
+// adminTokenKey is the context key of the *AdminToken of a request.
+type adminTokenKey struct{}
+
+// NewAdmin returns an admin API of the configuration file of r. Requests
+// must have an Authorization header with the bearer token of one of tokens.
+// Changes are written to the audit log, if not nil, as JSON lines of
+// AuditEntry.
+func NewAdmin(r *Reloader, tokens []AdminToken, audit io.Writer) (*Admin, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("vanity: no admin tokens")
+	}
+	names := make(map[string]bool)
+	for _, t := range tokens {
+		switch {
+		case t.Token == "":
+			return nil, fmt.Errorf("vanity: admin token %q is empty", t.Name)
+		case t.Name == "":
+			return nil, errors.New("vanity: admin token has no name")
+		case names[t.Name]:
+			return nil, fmt.Errorf("vanity: duplicate admin token name %q", t.Name)
+		}
+		names[t.Name] = true
+	}
+	if isGovanityurls(r.name) {
+		return nil, fmt.Errorf("vanity: config file %v in govanityurls format can't be updated", r.name)
+	}
+	a := &Admin{
+		r:      r,
+		tokens: tokens,
+		mux:    http.NewServeMux(),
+		audit:  audit,
+	}
+	a.mux.HandleFunc("GET "+adminModulesPath, a.listModules)
+	a.mux.HandleFunc("GET "+adminModulesPath+"/{path...}", a.getModule)
+	a.mux.HandleFunc("PUT "+adminModulesPath+"/{path...}", a.putModule)
+	a.mux.HandleFunc("PATCH "+adminModulesPath+"/{path...}", a.patchModule)
+	a.mux.HandleFunc("DELETE "+adminModulesPath+"/{path...}", a.deleteModule)
+	a.mux.HandleFunc("GET /revisions", a.listRevisions)
+	a.mux.HandleFunc("GET /revisions/{id}", a.getRevision)
+	a.mux.HandleFunc("GET /diff", a.diff)
+	a.mux.HandleFunc("POST /revisions/{id}/rollback", a.rollback)
+	return a, nil
+}
+
+// authenticate returns the token of the request, or nil. All tokens are
+// compared in constant time.
+func (a *Admin) authenticate(r *http.Request) *AdminToken {
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+	var found *AdminToken
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(bearer), []byte(a.tokens[i].Token)) == 1 {
+			found = &a.tokens[i]
+		}
+	}
+	return found
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := a.authenticate(r)
+	if token == nil {
+		err := errors.New("vanity: unauthorized")
+		if r.Method != http.MethodGet {
+			a.record(r, nil, strings.TrimPrefix(r.URL.Path, adminModulesPath), http.StatusUnauthorized, nil, nil, err)
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="vanity"`)
+		a.writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	a.mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminTokenKey{}, token)))
+}
+
+// requestToken returns the token of an authenticated request.
+func requestToken(r *http.Request) *AdminToken {
+	return r.Context().Value(adminTokenKey{}).(*AdminToken)
+}
+
+// record writes an entry of a change to the audit log.
+func (a *Admin) record(r *http.Request, token *AdminToken, path string, status int, before, after *Module, err error) {
+	a.recordEntry(r, token, AuditEntry{
+		Path:   path,
+		Status: status,
+		Before: before,
+		After:  after,
+	}, err)
+}
+
+// recordEntry writes entry e of the request to the audit log.
+func (a *Admin) recordEntry(r *http.Request, token *AdminToken, e AuditEntry, err error) {
+	if a.audit == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	e.Method = r.Method
+	if token != nil {
+		e.Token = token.Name
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		a.r.logger().Printf("vanity: error encoding audit entry: %v", err)
+		return
+	}
+	a.auditMu.Lock()
+	defer a.auditMu.Unlock()
+	if _, err := a.audit.Write(append(b, '\n')); err != nil {
+		a.r.logger().Printf("vanity: error writing audit log: %v", err)
+	}
+}
+
+// modules returns the modules currently served ordered by path.
+func (a *Admin) modules() []Module {
+	modules := append([]Module(nil), a.r.current.Load().modules...)
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Path < modules[j].Path
+	})
+	return modules
+}
+
+// listModules lists the modules the token of the request may manage.
+func (a *Admin) listModules(w http.ResponseWriter, r *http.Request) {
+	token := requestToken(r)
+	modules := []Module{}
+	for _, m := range a.modules() {
+		if token.allows(m.Path) {
+			modules = append(modules, m)
+		}
+	}
+	a.writeJSON(w, http.StatusOK, modules)
+}
+
+// forbidden returns an error if the token of the request may not manage the
+// module path.
+func forbidden(r *http.Request, path string) error {
+	token := requestToken(r)
+	if token.allows(path) {
+		return nil
+	}
+	return fmt.Errorf("vanity: token %q may not manage module %q", token.Name, path)
+}
+
+func (a *Admin) getModule(w http.ResponseWriter, r *http.Request) {
+	path := cleanModulePath(r.PathValue("path"))
+	if err := forbidden(r, path); err != nil {
+		a.writeError(w, http.StatusForbidden, err)
+		return
+	}
+	for _, m := range a.modules() {
+		if m.Path == path {
+			a.writeJSON(w, http.StatusOK, m)
+			return
+		}
+	}
+	a.writeError(w, http.StatusNotFound, errNotFound)
+}
+
+// findConfigModule returns the index of the module path in c, or -1.
+func findConfigModule(c *ConfigFile, path string) int {
+	for i, m := range c.Modules {
+		if cleanModulePath(m.Path) == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// change applies the change of the module path of the request to the
+// configuration file. The change returns the module before and after it,
+// nil if the module doesn't exist. The result is written as the response
+// and recorded in the audit log.
+func (a *Admin) change(w http.ResponseWriter, r *http.Request, change func(before *Module) (after *Module, err error)) {
+	path := cleanModulePath(r.PathValue("path"))
+	token := requestToken(r)
+	if err := forbidden(r, path); err != nil {
+		a.record(r, token, path, http.StatusForbidden, nil, nil, err)
+		a.writeError(w, http.StatusForbidden, err)
+		return
+	}
+	var before, after *Module
+	err := a.r.update(token.Name, func(c *ConfigFile) error {
+		i := findConfigModule(c, path)
+		if i >= 0 {
+			m := c.Modules[i]
+			before = &m
+		}
+		var err error
+		if after, err = change(before); err != nil {
+			return err
+		}
+		if token.scoped() {
+			if err := checkLocalPaths(before, after); err != nil {
+				return err
+			}
+		}
+		switch {
+		case after == nil:
+			c.Modules = append(c.Modules[:i], c.Modules[i+1:]...)
+		case i >= 0:
+			after.Path = path
+			c.Modules[i] = *after
+		default:
+			after.Path = path
+			c.Modules = append(c.Modules, *after)
+		}
+		return nil
+	})
+	status := http.StatusOK
+	switch {
+	case err != nil:
+		status = errorStatus(err)
+	case after == nil:
+		status = http.StatusNoContent
+	case before == nil:
+		status = http.StatusCreated
+	}
+	if err != nil {
+		before, after = nil, nil
+	}
+	a.record(r, token, path, status, before, after, err)
+	switch {
+	case err != nil:
+		if status == http.StatusInternalServerError {
+			a.r.logger().Printf("vanity: admin: %v", err)
+		}
+		a.writeError(w, status, err)
+	case after == nil:
+		w.WriteHeader(status)
+	default:
+		a.writeJSON(w, status, after)
+	}
+}
+
+// checkLocalPaths returns an error if the change of a module from before to
+// after sets its local paths. The paths may be kept or removed.
+func checkLocalPaths(before, after *Module) error {
+	if after == nil {
+		return nil
+	}
+	var old Module
+	if before != nil {
+		old = *before
+	}
+	for _, p := range []struct {
+		name       string
+		old, value string
+	}{
+		{"sourceDir", old.SourceDir, after.SourceDir},
+		{"repoDir", old.RepoDir, after.RepoDir},
+	} {
+		if p.value != "" && p.value != p.old {
+			return fmt.Errorf("%w: %v %q", errLocalPath, p.name, p.value)
+		}
+	}
+	return nil
+}
+
+// decodeModule decodes the JSON module of b onto m.
+func decodeModule(b []byte, m *Module) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(m); err != nil {
+		return &adminRequestError{fmt.Errorf("vanity: malformed module: %w", err)}
+	}
+	return nil
+}
+
+// readBody returns the request body, or writes an error response.
+func (a *Admin) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, fmt.Errorf("vanity: error reading request: %w", err))
+		return nil, false
+	}
+	return b, true
+}
+
+func (a *Admin) putModule(w http.ResponseWriter, r *http.Request) {
+	b, ok := a.readBody(w, r)
+	if !ok {
+		return
+	}
+	a.change(w, r, func(*Module) (*Module, error) {
+		var m Module
+		if err := decodeModule(b, &m); err != nil {
+			return nil, err
+		}
+		return &m, nil
+	})
+}
+
+func (a *Admin) patchModule(w http.ResponseWriter, r *http.Request) {
+	b, ok := a.readBody(w, r)
+	if !ok {
+		return
+	}
+	a.change(w, r, func(before *Module) (*Module, error) {
+		if before == nil {
+			return nil, errNotFound
+		}
+		m := *before
+		if err := decodeModule(b, &m); err != nil {
+			return nil, err
+		}
+		return &m, nil
+	})
+}
+
+func (a *Admin) deleteModule(w http.ResponseWriter, r *http.Request) {
+	a.change(w, r, func(before *Module) (*Module, error) {
+		if before == nil {
+			return nil, errNotFound
+		}
+		return nil, nil
+	})
+}
+
+// adminRequestError is an error of a malformed request.
+type adminRequestError struct {
+	err error
+}
+
+func (e *adminRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *adminRequestError) Unwrap() error {
+	return e.err
+}
+
+// errorStatus returns the response status of the error of a configuration
+// change. Unknown modules are not found, local paths set by tokens limited
+// to a prefix are forbidden, and invalid configurations and malformed
+// requests are bad requests.
+func errorStatus(err error) int {
+	var reqErr *adminRequestError
+	var validationErr *validationError
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errLocalPath):
+		return http.StatusForbidden
+	case errors.As(err, &reqErr), errors.As(err, &validationErr):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (a *Admin) writeError(w http.ResponseWriter, status int, err error) {
+	a.writeJSON(w, status, adminError{Error: err.Error()})
+}
+
+func (a *Admin) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	a.r.current.Load().writeJSON(w, status, v)
+}
+
+// history returns the configuration history, or writes an error response if
+// there is no history or the token of the request is limited to a prefix.
+func (a *Admin) history(w http.ResponseWriter, r *http.Request) *History {
+	if token := requestToken(r); token.scoped() {
+		a.writeError(w, http.StatusForbidden, fmt.Errorf("vanity: token %q may not manage revisions", token.Name))
+		return nil
+	}
+	h := a.r.History()
+	if h == nil {
+		a.writeError(w, http.StatusNotFound, errNoHistory)
+	}
+	return h
+}
+
+// revisionID returns the revision ID of the path value or query parameter
+// name, or writes an error response.
+func (a *Admin) revisionID(w http.ResponseWriter, name, value string) (int, bool) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, fmt.Errorf("vanity: malformed revision %v %q", name, value))
+		return 0, false
+	}
+	return id, true
+}
+
+func (a *Admin) listRevisions(w http.ResponseWriter, r *http.Request) {
+	h := a.history(w, r)
+	if h == nil {
+		return
+	}
+	revisions := h.Revisions()
+	for i := range revisions {
+		revisions[i].Config = ""
+	}
+	a.writeJSON(w, http.StatusOK, revisions)
+}
+
+func (a *Admin) getRevision(w http.ResponseWriter, r *http.Request) {
+	h := a.history(w, r)
+	if h == nil {
+		return
+	}
+	id, ok := a.revisionID(w, "id", r.PathValue("id"))
+	if !ok {
+		return
+	}
+	rev, err := h.Revision(id)
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, rev)
+}
+
+func (a *Admin) diff(w http.ResponseWriter, r *http.Request) {
+	h := a.history(w, r)
+	if h == nil {
+		return
+	}
+	from, ok := a.revisionID(w, "from", r.URL.Query().Get("from"))
+	if !ok {
+		return
+	}
+	revisions := h.Revisions()
+	if len(revisions) == 0 {
+		a.writeError(w, http.StatusNotFound, errNoHistory)
+		return
+	}
+	to := revisions[len(revisions)-1].ID
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, ok = a.revisionID(w, "to", v); !ok {
+			return
+		}
+	}
+	diff, err := h.Diff(from, to)
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, diff)
+}
+
+func (a *Admin) rollback(w http.ResponseWriter, r *http.Request) {
+	h := a.history(w, r)
+	if h == nil {
+		return
+	}
+	id, ok := a.revisionID(w, "id", r.PathValue("id"))
+	if !ok {
+		return
+	}
+	token := requestToken(r)
+	status := http.StatusOK
+	err := a.r.Rollback(id, token.Name)
+	var validationErr *validationError
+	switch {
+	case err == nil:
+	case errors.Is(err, errUnknownRevision):
+		status = http.StatusNotFound
+	case errors.As(err, &validationErr):
+		status = http.StatusBadRequest
+	default:
+		status = http.StatusInternalServerError
+		a.r.logger().Printf("vanity: admin: %v", err)
+	}
+	a.recordEntry(r, token, AuditEntry{Status: status, Revision: id}, err)
+	if err != nil {
+		a.writeError(w, status, err)
+		return
+	}
+	revisions := h.Revisions()
+	a.writeJSON(w, status, revisions[len(revisions)-1])
+}
@@ -0,0 +1,672 @@
+package vanity_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kkn.fi/vanity"
+)
+
+const adminToken = "secret"
+
+func adminRequest(t *testing.T, h http.Handler, method, path, token, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, "https://admin.kkn.fi"+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestAdmin(t *testing.T) {
+	dir := t.TempDir()
+	name := writeConfigFile(t, dir, `{"domain": "kkn.fi", "vcsURL": "https://github.com/kare", "modules": [{"path": "/x", "repoURL": "https://github.com/kare/x"}]}`)
+	r, err := vanity.NewReloader(name, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := vanity.NewAdmin(r, []vanity.AdminToken{{Name: "admin", Token: adminToken}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		// contains is a substring of the response body.
+		contains string
+		// goImport is the go-import meta tag of /y after the request.
+		goImport string
+	}{
+		{
+			name:     "missing token",
+			method:   http.MethodGet,
+			path:     "/modules",
+			status:   http.StatusUnauthorized,
+			contains: `"error": "vanity: unauthorized"`,
+		},
+		{
+			name:     "wrong token",
+			method:   http.MethodGet,
+			path:     "/modules",
+			token:    "guess",
+			status:   http.StatusUnauthorized,
+			contains: `"error": "vanity: unauthorized"`,
+		},
+		{
+			name:     "list",
+			method:   http.MethodGet,
+			path:     "/modules",
+			token:    adminToken,
+			status:   http.StatusOK,
+			contains: `"path": "/x"`,
+			goImport: `<meta name="go-import" content="kkn.fi/y git https://github.com/kare/y">`,
+		},
+		{
+			name:     "add",
+			method:   http.MethodPut,
+			path:     "/modules/y",
+			token:    adminToken,
+			body:     `{"repoURL": "https://gitlab.com/kare/y", "vcs": "git"}`,
+			status:   http.StatusCreated,
+			contains: `"path": "/y"`,
+			goImport: `<meta name="go-import" content="kkn.fi/y git https://gitlab.com/kare/y">`,
+		},
+		{
+			name:     "invalid module",
+			method:   http.MethodPut,
+			path:     "/modules/y",
+			token:    adminToken,
+			body:     `{"repoURL": "https://hg.kkn.fi/y", "vcs": "cvs"}`,
+			status:   http.StatusBadRequest,
+			contains: `vanity: module \"/y\" has unknown VCS \"cvs\"`,
+			goImport: `<meta name="go-import" content="kkn.fi/y git https://gitlab.com/kare/y">`,
+		},
+		{
+			name:     "malformed module",
+			method:   http.MethodPut,
+			path:     "/modules/y",
+			token:    adminToken,
+			body:     `{"repo": "https://hg.kkn.fi/y"}`,
+			status:   http.StatusBadRequest,
+			contains: `vanity: malformed module`,
+			goImport: `<meta name="go-import" content="kkn.fi/y git https://gitlab.com/kare/y">`,
+		},
+		{
+			name:     "replace",
+			method:   http.MethodPut,
+			path:     "/modules/y",
+			token:    adminToken,
+			body:     `{"repoURL": "https://hg.kkn.fi/y", "vcs": "hg"}`,
+			status:   http.StatusOK,
+			contains: `"vcs": "hg"`,
+			goImport: `<meta name="go-import" content="kkn.fi/y hg https://hg.kkn.fi/y">`,
+		},
+		{
+			name:     "deprecate",
+			method:   http.MethodPatch,
+			path:     "/modules/y",
+			token:    adminToken,
+			body:     `{"state": "deprecated"}`,
+			status:   http.StatusOK,
+			contains: `"state": "deprecated"`,
+			goImport: `<meta name="go-import" content="kkn.fi/y hg https://hg.kkn.fi/y">`,
+		},
+		{
+			name:     "get",
+			method:   http.MethodGet,
+			path:     "/modules/y",
+			token:    adminToken,
+			status:   http.StatusOK,
+			contains: `"repoURL": "https://hg.kkn.fi/y"`,
+		},
+		{
+			name:     "patch unknown",
+			method:   http.MethodPatch,
+			path:     "/modules/z",
+			token:    adminToken,
+			body:     `{"state": "deprecated"}`,
+			status:   http.StatusNotFound,
+			contains: `"error": "vanity: module not found"`,
+		},
+		{
+			name:     "remove",
+			method:   http.MethodDelete,
+			path:     "/modules/y",
+			token:    adminToken,
+			status:   http.StatusNoContent,
+			goImport: `<meta name="go-import" content="kkn.fi/y git https://github.com/kare/y">`,
+		},
+		{
+			name:     "get removed",
+			method:   http.MethodGet,
+			path:     "/modules/y",
+			token:    adminToken,
+			status:   http.StatusNotFound,
+			contains: `"error": "vanity: module not found"`,
+		},
+	}
+	for _, step := range steps {
+		status, body := adminRequest(t, admin, step.method, step.path, step.token, step.body)
+		if status != step.status {
+			t.Errorf("%v: expected response status %v, but got %v: %v", step.name, step.status, status, body)
+		}
+		if !strings.Contains(body, step.contains) {
+			t.Errorf("%v: expected body to contain %v, but got %v", step.name, step.contains, body)
+		}
+		if step.goImport == "" {
+			continue
+		}
+		if got := goImport(t, r, "/y"); got != step.goImport {
+			t.Errorf("%v: expected %v, but got %v", step.name, step.goImport, got)
+		}
+	}
+
+	// Changes are persisted to the configuration file.
+	if status, body := adminRequest(t, admin, http.MethodPut, "/modules/cmd/z", adminToken, `{"description": "Z <3"}`); status != http.StatusCreated {
+		t.Fatalf("expected response status 201, but got %v: %v", status, body)
+	}
+	c, err := vanity.ReadConfigFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Domain != "kkn.fi" || len(c.Modules) != 2 || c.Modules[1].Path != "/cmd/z" || c.Modules[1].Description != "Z <3" {
+		t.Errorf("unexpected configuration file: %+v", c)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the configuration file, but got %v entries", len(entries))
+	}
+}
+
+func TestNewAdminErrors(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "vanity.yaml")
+	if err := os.WriteFile(name, []byte("paths:\n  /x:\n    repo: https://github.com/kare/x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := vanity.NewReloader(name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vanity.NewAdmin(r, []vanity.AdminToken{{Name: "admin", Token: adminToken}}, nil); err == nil {
+		t.Error("expected an error of a govanityurls configuration file")
+	}
+	r, err = vanity.NewReloader(writeConfigFile(t, dir, `{}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		tokens []vanity.AdminToken
+	}{
+		{
+			name: "no tokens",
+		},
+		{
+			name:   "empty token",
+			tokens: []vanity.AdminToken{{Name: "admin"}},
+		},
+		{
+			name:   "no name",
+			tokens: []vanity.AdminToken{{Token: adminToken}},
+		},
+		{
+			name: "duplicate name",
+			tokens: []vanity.AdminToken{
+				{Name: "admin", Token: adminToken},
+				{Name: "admin", Token: "other"},
+			},
+		},
+	}
+	for _, test := range tests {
+		if _, err := vanity.NewAdmin(r, test.tokens, nil); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+func TestAdminScopes(t *testing.T) {
+	name := writeConfigFile(t, t.TempDir(), `{"vcsURL": "https://github.com/kare", "modules": [{"path": "/payments/ledger"}, {"path": "/infra/dns"}]}`)
+	r, err := vanity.NewReloader(name, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var audit bytes.Buffer
+	admin, err := vanity.NewAdmin(r, []vanity.AdminToken{
+		{Name: "payments", Token: "payments-secret", Prefix: "/payments"},
+		{Name: "infra", Token: "infra-secret", Prefix: "infra/"},
+		{Name: "admin", Token: adminToken},
+	}, &audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		status   int
+		contains string
+		excludes string
+	}{
+		{
+			name:     "list own prefix",
+			method:   http.MethodGet,
+			path:     "/modules",
+			token:    "payments-secret",
+			status:   http.StatusOK,
+			contains: `"path": "/payments/ledger"`,
+			excludes: `/infra/dns`,
+		},
+		{
+			name:   "add under own prefix",
+			method: http.MethodPut,
+			path:   "/modules/payments/cards",
+			token:  "payments-secret",
+			body:   `{"repoURL": "https://github.com/kare/cards"}`,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "prefix itself",
+			method: http.MethodPut,
+			path:   "/modules/payments",
+			token:  "payments-secret",
+			body:   `{}`,
+			status: http.StatusCreated,
+		},
+		{
+			name:     "similar prefix",
+			method:   http.MethodPut,
+			path:     "/modules/paymentsx",
+			token:    "payments-secret",
+			body:     `{}`,
+			status:   http.StatusForbidden,
+			contains: `vanity: token \"payments\" may not manage module \"/paymentsx\"`,
+		},
+		{
+			name:   "other prefix",
+			method: http.MethodDelete,
+			path:   "/modules/infra/dns",
+			token:  "payments-secret",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "get other prefix",
+			method: http.MethodGet,
+			path:   "/modules/infra/dns",
+			token:  "payments-secret",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "deprecate own prefix",
+			method: http.MethodPatch,
+			path:   "/modules/infra/dns",
+			token:  "infra-secret",
+			body:   `{"state": "deprecated"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "unscoped token",
+			method: http.MethodDelete,
+			path:   "/modules/payments/ledger",
+			token:  adminToken,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "unauthorized",
+			method: http.MethodDelete,
+			path:   "/modules/infra/dns",
+			token:  "guess",
+			status: http.StatusUnauthorized,
+		},
+	}
+	for _, step := range steps {
+		status, body := adminRequest(t, admin, step.method, step.path, step.token, step.body)
+		if status != step.status {
+			t.Errorf("%v: expected response status %v, but got %v: %v", step.name, step.status, status, body)
+		}
+		if !strings.Contains(body, step.contains) {
+			t.Errorf("%v: expected body to contain %v, but got %v", step.name, step.contains, body)
+		}
+		if step.excludes != "" && strings.Contains(body, step.excludes) {
+			t.Errorf("%v: expected body not to contain %v, but got %v", step.name, step.excludes, body)
+		}
+	}
+
+	var entries []vanity.AuditEntry
+	dec := json.NewDecoder(&audit)
+	for dec.More() {
+		var e vanity.AuditEntry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("audit entry %+v has no time", e)
+		}
+		e.Time = time.Time{}
+		entries = append(entries, e)
+	}
+	want := []string{
+		`payments PUT /payments/cards 201 <nil> /payments/cards`,
+		`payments PUT /payments 201 <nil> /payments`,
+		`payments PUT /paymentsx 403 <nil> <nil> vanity: token "payments" may not manage module "/paymentsx"`,
+		`payments DELETE /infra/dns 403 <nil> <nil> vanity: token "payments" may not manage module "/infra/dns"`,
+		`infra PATCH /infra/dns 200 /infra/dns /infra/dns:deprecated`,
+		`admin DELETE /payments/ledger 204 /payments/ledger <nil>`,
+		`DELETE /infra/dns 401 <nil> <nil> vanity: unauthorized`,
+	}
+	module := func(m *vanity.Module) string {
+		switch {
+		case m == nil:
+			return "<nil>"
+		case m.State != "":
+			return m.Path + ":" + string(m.State)
+		}
+		return m.Path
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %v audit entries, but got %v", len(want), len(entries))
+	}
+	for i, e := range entries {
+		got := strings.TrimSpace(fmt.Sprintf("%v %v %v %v %v %v %v", e.Token, e.Method, e.Path, e.Status, module(e.Before), module(e.After), e.Error))
+		if got != want[i] {
+			t.Errorf("audit entry %v: expected %q, but got %q", i, want[i], got)
+		}
+	}
+}
+
+func TestAdminRevisions(t *testing.T) {
+	dir := t.TempDir()
+	name := writeConfigFile(t, dir, `{"vcsURL": "https://github.com/kare"}`)
+	r, err := vanity.NewReloader(name, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var audit bytes.Buffer
+	admin, err := vanity.NewAdmin(r, []vanity.AdminToken{
+		{Name: "admin", Token: adminToken},
+		{Name: "payments", Token: "payments-secret", Prefix: "/payments"},
+	}, &audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := adminRequest(t, admin, http.MethodGet, "/revisions", adminToken, ""); status != http.StatusNotFound {
+		t.Errorf("expected response status 404 without history, but got %v", status)
+	}
+	h, err := vanity.OpenHistory(filepath.Join(dir, "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	r.SetHistory(h)
+	if status, body := adminRequest(t, admin, http.MethodPut, "/modules/payments/cards", "payments-secret", `{"repoURL": "https://example.com/cards"}`); status != http.StatusCreated {
+		t.Fatalf("expected response status 201, but got %v: %v", status, body)
+	}
+	const (
+		before = `<meta name="go-import" content="kkn.fi/payments/cards git https://github.com/kare/payments">`
+		after  = `<meta name="go-import" content="kkn.fi/payments/cards git https://example.com/cards">`
+	)
+	if got := goImport(t, r, "/payments/cards"); got != after {
+		t.Fatalf("expected %v, but got %v", after, got)
+	}
+	steps := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		status   int
+		contains string
+		excludes string
+	}{
+		{
+			name:     "list",
+			method:   http.MethodGet,
+			path:     "/revisions",
+			token:    adminToken,
+			status:   http.StatusOK,
+			contains: `"author": "payments"`,
+			excludes: `"config"`,
+		},
+		{
+			name:     "get",
+			method:   http.MethodGet,
+			path:     "/revisions/1",
+			token:    adminToken,
+			status:   http.StatusOK,
+			contains: `"config": "{\"vcsURL\": \"https://github.com/kare\"}"`,
+		},
+		{
+			name:     "get unknown",
+			method:   http.MethodGet,
+			path:     "/revisions/3",
+			token:    adminToken,
+			status:   http.StatusNotFound,
+			contains: `vanity: unknown revision 3`,
+		},
+		{
+			name:     "diff to latest",
+			method:   http.MethodGet,
+			path:     "/diff?from=1",
+			token:    adminToken,
+			status:   http.StatusOK,
+			contains: "--- revision 1\n+++ revision 2\n",
+		},
+		{
+			name:     "malformed diff",
+			method:   http.MethodGet,
+			path:     "/diff?from=first",
+			token:    adminToken,
+			status:   http.StatusBadRequest,
+			contains: `vanity: malformed revision from \"first\"`,
+		},
+		{
+			name:     "scoped token",
+			method:   http.MethodPost,
+			path:     "/revisions/1/rollback",
+			token:    "payments-secret",
+			status:   http.StatusForbidden,
+			contains: `vanity: token \"payments\" may not manage revisions`,
+		},
+		{
+			name:     "rollback",
+			method:   http.MethodPost,
+			path:     "/revisions/1/rollback",
+			token:    adminToken,
+			status:   http.StatusOK,
+			contains: `"rollback": 1`,
+		},
+		{
+			name:   "rollback unknown",
+			method: http.MethodPost,
+			path:   "/revisions/9/rollback",
+			token:  adminToken,
+			status: http.StatusNotFound,
+		},
+	}
+	for _, step := range steps {
+		status, body := adminRequest(t, admin, step.method, step.path, step.token, "")
+		if status != step.status {
+			t.Errorf("%v: expected response status %v, but got %v: %v", step.name, step.status, status, body)
+		}
+		if !strings.Contains(body, step.contains) {
+			t.Errorf("%v: expected body to contain %q, but got %v", step.name, step.contains, body)
+		}
+		if step.excludes != "" && strings.Contains(body, step.excludes) {
+			t.Errorf("%v: expected body not to contain %v, but got %v", step.name, step.excludes, body)
+		}
+	}
+	if got := goImport(t, r, "/payments/cards"); got != before {
+		t.Errorf("expected %v after rollback, but got %v", before, got)
+	}
+	if !strings.Contains(audit.String(), `"token":"admin","method":"POST","path":"","status":200,"revision":1`) {
+		t.Errorf("expected rollback in audit log, but got %v", audit.String())
+	}
+}
+
+func TestAdminScopedLocalPaths(t *testing.T) {
+	dir := t.TempDir()
+	name := writeConfigFile(t, dir, `{"vcsURL": "https://github.com/kare", "modules": [{"path": "/payments/ledger"}]}`)
+	r, err := vanity.NewReloader(name, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := vanity.NewAdmin(r, []vanity.AdminToken{
+		{Name: "payments", Token: "payments-secret", Prefix: "/payments"},
+		{Name: "admin", Token: adminToken},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		status   int
+		contains string
+	}{
+		{
+			name:     "add source dir",
+			method:   http.MethodPut,
+			path:     "/modules/payments/cards",
+			token:    "payments-secret",
+			body:     `{"sourceDir": "/"}`,
+			status:   http.StatusForbidden,
+			contains: `vanity: token limited to a prefix may not set local paths: sourceDir \"/\"`,
+		},
+		{
+			name:     "set repo dir",
+			method:   http.MethodPatch,
+			path:     "/modules/payments/ledger",
+			token:    "payments-secret",
+			body:     `{"repoDir": "` + dir + `"}`,
+			status:   http.StatusForbidden,
+			contains: `repoDir`,
+		},
+		{
+			name:   "unscoped token",
+			method: http.MethodPatch,
+			path:   "/modules/payments/ledger",
+			token:  adminToken,
+			body:   `{"sourceDir": "` + dir + `"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "keep source dir",
+			method: http.MethodPatch,
+			path:   "/modules/payments/ledger",
+			token:  "payments-secret",
+			body:   `{"state": "deprecated"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:     "change source dir",
+			method:   http.MethodPatch,
+			path:     "/modules/payments/ledger",
+			token:    "payments-secret",
+			body:     `{"sourceDir": "/"}`,
+			status:   http.StatusForbidden,
+			contains: `sourceDir`,
+		},
+		{
+			name:   "remove source dir",
+			method: http.MethodPut,
+			path:   "/modules/payments/ledger",
+			token:  "payments-secret",
+			body:   `{}`,
+			status: http.StatusOK,
+		},
+	}
+	for _, step := range steps {
+		status, body := adminRequest(t, admin, step.method, step.path, step.token, step.body)
+		if status != step.status {
+			t.Errorf("%v: expected response status %v, but got %v: %v", step.name, step.status, status, body)
+		}
+		if !strings.Contains(body, step.contains) {
+			t.Errorf("%v: expected body to contain %v, but got %v", step.name, step.contains, body)
+		}
+	}
+	c, err := vanity.ReadConfigFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Modules) != 1 || c.Modules[0].SourceDir != "" || c.Modules[0].RepoDir != "" {
+		t.Errorf("unexpected modules: %+v", c.Modules)
+	}
+}
+
+func TestAdminDotSegments(t *testing.T) {
+	const file = `{"vcsURL": "https://github.com/kare", "modules": [{"path": "/payments/ledger"}]}`
+	name := writeConfigFile(t, t.TempDir(), file)
+	r, err := vanity.NewReloader(name, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := vanity.NewAdmin(r, []vanity.AdminToken{
+		{Name: "payments", Token: "payments-secret", Prefix: "/payments"},
+		{Name: "admin", Token: adminToken},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{
+			name:   "escape prefix",
+			path:   "/modules/payments%2F..%2Finfra",
+			token:  "payments-secret",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "parent of prefix",
+			path:   "/modules/payments%2F%2E%2E",
+			token:  "payments-secret",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "unscoped token",
+			path:   "/modules/payments%2F..%2Finfra",
+			token:  adminToken,
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, step := range steps {
+		body := `{"repoURL": "https://evil.example/x"}`
+		status, res := adminRequest(t, admin, http.MethodPut, step.path, step.token, body)
+		if status != step.status {
+			t.Errorf("%v: expected response status %v, but got %v: %v", step.name, step.status, status, res)
+		}
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != file {
+		t.Errorf("expected configuration file to be unchanged, but got %s", b)
+	}
+}
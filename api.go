@@ -0,0 +1,89 @@
+package vanity
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// apiModulesPath is the path of the JSON API listing modules. A module is
+// described at the path followed by the module path, such as
+// /api/modules/cmd/tcpproxy.
+const apiModulesPath = "/api/modules"
+
+// moduleList is the JSON response of the module listing.
+type moduleList struct {
+	Domain  string        `json:"domain"`
+	Modules []*ModulePage `json:"modules"`
+}
+
+// modulePages returns the page data of the public modules ordered by path.
+func (h *handler) modulePages(r *http.Request, domain string) []*ModulePage {
+	modules := h.publicModules()
+	pages := make([]*ModulePage, 0, len(modules))
+	for _, m := range modules {
+		pages = append(pages, h.modulePage(h.resolveRequest(r, domain, m.Path)))
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].Path < pages[j].Path
+	})
+	return pages
+}
+
+// serveAPI responds to the JSON API requests. It reports false if the
+// request path is not an API path.
+func (h *handler) serveAPI(w http.ResponseWriter, r *http.Request, domain string) bool {
+	switch {
+	case r.URL.Path == apiModulesPath || r.URL.Path == apiModulesPath+"/":
+		h.writeJSON(w, http.StatusOK, moduleList{
+			Domain:  domain,
+			Modules: h.modulePages(r, domain),
+		})
+	case strings.HasPrefix(r.URL.Path, apiModulesPath+"/"):
+		h.writeJSON(w, http.StatusOK, h.modulePage(h.resolveRequest(r, domain, strings.TrimPrefix(r.URL.Path, apiModulesPath))))
+	default:
+		return false
+	}
+	return true
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func (h *handler) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		h.log.Printf("vanity: i/o error writing json response: %v", err)
+	}
+}
+
+// acceptsJSON reports whether the client prefers JSON over HTML according to
+// the Accept request header.
+func acceptsJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			switch mediaType {
+			case "application/json":
+				jsonQ = max(jsonQ, q)
+			case "text/html":
+				htmlQ = max(htmlQ, q)
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ >= htmlQ
+}
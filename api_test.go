@@ -0,0 +1,161 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestAPI(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		status int
+		body   string
+	}{
+		{
+			name:   "list modules",
+			path:   "/api/modules",
+			status: http.StatusOK,
+			body: `{
+  "domain": "kkn.fi",
+  "modules": [
+    {
+      "path": "/cmd/tcpproxy",
+      "importPath": "kkn.fi/cmd/tcpproxy",
+      "importPrefix": "kkn.fi/cmd/tcpproxy",
+      "moduleRoot": "kkn.fi/cmd/tcpproxy",
+      "vcs": "git",
+      "repoURL": "https://github.com/kare/tcpproxy",
+      "docsURL": "https://pkg.go.dev/kkn.fi/cmd/tcpproxy",
+      "state": "active",
+      "command": true
+    },
+    {
+      "path": "/legacy",
+      "importPath": "kkn.fi/legacy",
+      "importPrefix": "kkn.fi/legacy",
+      "moduleRoot": "kkn.fi/legacy",
+      "vcs": "hg",
+      "repoURL": "https://hg.kkn.fi/legacy",
+      "docsURL": "https://godocs.io/kkn.fi/legacy",
+      "state": "deprecated",
+      "description": "Legacy code.",
+      "license": "MIT",
+      "command": false
+    }
+  ]
+}
+`,
+		},
+		{
+			name:   "module package",
+			path:   "/api/modules/legacy/sub",
+			status: http.StatusOK,
+			body: `{
+  "path": "/legacy/sub",
+  "importPath": "kkn.fi/legacy/sub",
+  "importPrefix": "kkn.fi/legacy",
+  "moduleRoot": "kkn.fi/legacy",
+  "vcs": "hg",
+  "repoURL": "https://hg.kkn.fi/legacy",
+  "docsURL": "https://godocs.io/kkn.fi/legacy/sub",
+  "state": "deprecated",
+  "description": "Legacy code.",
+  "license": "MIT",
+  "command": false
+}
+`,
+		},
+		{
+			name:   "content negotiation",
+			path:   "/vanity",
+			accept: "application/json",
+			status: http.StatusOK,
+			body: `{
+  "path": "/vanity",
+  "importPath": "kkn.fi/vanity",
+  "importPrefix": "kkn.fi/vanity",
+  "moduleRoot": "kkn.fi/vanity",
+  "vcs": "git",
+  "repoURL": "https://github.com/kare/vanity",
+  "docsURL": "https://pkg.go.dev/kkn.fi/vanity",
+  "state": "active",
+  "command": false
+}
+`,
+		},
+		{
+			name:   "browser prefers html",
+			path:   "/vanity",
+			accept: "text/html,application/xhtml+xml,application/xml;q=0.9,application/json;q=0.8,*/*;q=0.7",
+			status: http.StatusTemporaryRedirect,
+		},
+		{
+			name:   "json with lower quality",
+			path:   "/vanity",
+			accept: "application/json;q=0.5, text/html",
+			status: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			srv, err := vanity.NewHandlerWithOptions(
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(
+					vanity.Module{
+						Path:        "/legacy",
+						VCS:         "hg",
+						RepoURL:     "https://hg.kkn.fi/legacy",
+						DocsURL:     vanity.DocsGodocsIO,
+						Description: "Legacy code.",
+						License:     "MIT",
+						State:       vanity.StateDeprecated,
+					},
+					vanity.Module{Path: "/cmd/tcpproxy"},
+				),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != test.status {
+				t.Errorf("expected response status %v, but got %v", test.status, res.StatusCode)
+			}
+			if test.body == "" {
+				return
+			}
+			if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("unexpected content type %v", ct)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if string(body) != test.body {
+				t.Errorf("expecting body\n%v, but got\n%s", test.body, body)
+			}
+		})
+	}
+}
+
+func TestModuleStateInvalid(t *testing.T) {
+	_, err := vanity.NewHandlerWithOptions(
+		vanity.Modules(vanity.Module{Path: "/vanity", State: "archived"}),
+	)
+	if err == nil {
+		t.Error("expecting error, but got nil")
+	}
+}
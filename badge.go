@@ -0,0 +1,148 @@
+package vanity
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// badgeSuffix is the path suffix of the SVG badge of a module.
+const badgeSuffix = "/badge.svg"
+
+// badgeMaxAge is the time in seconds clients may cache badges.
+const badgeMaxAge = 3600
+
+// Badge colors.
+const (
+	badgeLabelColor   = "#555"
+	badgeGoColor      = "#007d9c"
+	badgeVersionColor = "#007ec6"
+	badgeUnknownColor = "#9f9f9f"
+)
+
+// badgeTemplate is a flat badge with a label and a value. Arguments are the
+// total width, label width, value width, label color, value color, label
+// text, label x, value text and value x.
+const badgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[6]s: %[8]s">
+  <title>%[6]s: %[8]s</title>
+  <rect width="%[2]d" height="20" fill="%[4]s"/>
+  <rect x="%[2]d" width="%[3]d" height="20" fill="%[5]s"/>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="%[7]d" y="14">%[6]s</text>
+    <text x="%[9]d" y="14">%[8]s</text>
+  </g>
+</svg>
+`
+
+// badgeTextWidth estimates the rendered width of s in pixels.
+func badgeTextWidth(s string) int {
+	return len([]rune(s))*7 + 10
+}
+
+// badge returns a flat SVG badge.
+func badge(label, value, color string) []byte {
+	lw, vw := badgeTextWidth(label), badgeTextWidth(value)
+	return []byte(fmt.Sprintf(badgeTemplate,
+		lw+vw, lw, vw,
+		badgeLabelColor, color,
+		xmlEscape(label), lw/2,
+		xmlEscape(value), lw+vw/2,
+	))
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// goDirective returns the go directive version of the go.mod file of the
+// module of t. The go.mod file is read from the local sources, or from the
+// HEAD of the local repository. An empty string is returned if the version
+// is not known, with an error if go.mod can't be read from the repository.
+func goDirective(t *target) (string, error) {
+	if t.module == nil {
+		return "", nil
+	}
+	var gomod []byte
+	switch m := t.module; {
+	case m.SourceDir != "":
+		b, err := os.ReadFile(filepath.Join(m.SourceDir, "go.mod"))
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("vanity: error reading go.mod of %v: %w", t.moduleRoot, err)
+		}
+		gomod = b
+	case m.RepoDir != "":
+		b, err := runGit(context.Background(), m.RepoDir, "show", "HEAD:"+path.Join(m.Subdir, "go.mod"))
+		if err != nil {
+			return "", fmt.Errorf("vanity: error reading go.mod of %v: %w", t.moduleRoot, err)
+		}
+		gomod = b
+	default:
+		return "", nil
+	}
+	return parseGoDirective(gomod), nil
+}
+
+// parseGoDirective returns the version of the go directive of a go.mod file.
+func parseGoDirective(gomod []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(gomod))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "go" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// serveBadge responds with an SVG badge of the module of t. The type query
+// parameter selects the badge: reference (default), version for the latest
+// version, or go for the go directive version of go.mod.
+func (h *handler) serveBadge(w http.ResponseWriter, r *http.Request, t *target) {
+	var svg []byte
+	switch r.FormValue("type") {
+	case "", "reference":
+		svg = badge("go", "reference", badgeGoColor)
+	case "version":
+		versions, err := h.versions(t)
+		if err != nil && !errors.Is(err, errNoRepoDir) {
+			h.log.Printf("%v", err)
+		}
+		if latest, ok := latestVersion(versions); ok {
+			svg = badge("version", latest.Version, badgeVersionColor)
+		} else {
+			svg = badge("version", "unknown", badgeUnknownColor)
+		}
+	case "go":
+		version, err := goDirective(t)
+		if err != nil {
+			h.log.Printf("%v", err)
+		}
+		if version != "" {
+			svg = badge("go", version, badgeGoColor)
+		} else {
+			svg = badge("go", "unknown", badgeUnknownColor)
+		}
+	default:
+		status := http.StatusBadRequest
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", badgeMaxAge))
+	if _, err := w.Write(svg); err != nil {
+		h.log.Printf("vanity: i/o error writing badge response: %v", err)
+	}
+}
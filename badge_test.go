@@ -0,0 +1,121 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestBadge(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "go reference",
+			path:   "/greet/badge.svg",
+			status: http.StatusOK,
+			body: `<svg xmlns="http://www.w3.org/2000/svg" width="97" height="20" role="img" aria-label="go: reference">
+  <title>go: reference</title>
+  <rect width="24" height="20" fill="#555"/>
+  <rect x="24" width="73" height="20" fill="#007d9c"/>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="12" y="14">go</text>
+    <text x="60" y="14">reference</text>
+  </g>
+</svg>
+`,
+		},
+		{
+			name:   "go directive from local sources",
+			path:   "/greet/badge.svg?type=go",
+			status: http.StatusOK,
+			body: `<svg xmlns="http://www.w3.org/2000/svg" width="62" height="20" role="img" aria-label="go: 1.24">
+  <title>go: 1.24</title>
+  <rect width="24" height="20" fill="#555"/>
+  <rect x="24" width="38" height="20" fill="#007d9c"/>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="12" y="14">go</text>
+    <text x="43" y="14">1.24</text>
+  </g>
+</svg>
+`,
+		},
+		{
+			name:   "unknown go directive",
+			path:   "/vanity/badge.svg?type=go",
+			status: http.StatusOK,
+			body: `<svg xmlns="http://www.w3.org/2000/svg" width="83" height="20" role="img" aria-label="go: unknown">
+  <title>go: unknown</title>
+  <rect width="24" height="20" fill="#555"/>
+  <rect x="24" width="59" height="20" fill="#9f9f9f"/>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="12" y="14">go</text>
+    <text x="53" y="14">unknown</text>
+  </g>
+</svg>
+`,
+		},
+		{
+			name:   "unknown version",
+			path:   "/vanity/badge.svg?type=version",
+			status: http.StatusOK,
+			body: `<svg xmlns="http://www.w3.org/2000/svg" width="118" height="20" role="img" aria-label="version: unknown">
+  <title>version: unknown</title>
+  <rect width="59" height="20" fill="#555"/>
+  <rect x="59" width="59" height="20" fill="#9f9f9f"/>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="29" y="14">version</text>
+    <text x="88" y="14">unknown</text>
+  </g>
+</svg>
+`,
+		},
+		{
+			name:   "unknown badge type",
+			path:   "/vanity/badge.svg?type=coverage",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv, err := vanity.NewHandlerWithOptions(
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(vanity.Module{Path: "/greet", SourceDir: "testdata/docs/greet"}),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != test.status {
+				t.Errorf("expected response status %v, but got %v", test.status, res.StatusCode)
+			}
+			if test.body == "" {
+				return
+			}
+			if ct := res.Header.Get("Content-Type"); ct != "image/svg+xml" {
+				t.Errorf("unexpected content type %v", ct)
+			}
+			if cc := res.Header.Get("Cache-Control"); cc != "public, max-age=3600" {
+				t.Errorf("unexpected cache control %v", cc)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if string(body) != test.body {
+				t.Errorf("expecting body\n%v, but got\n%s", test.body, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package vanity
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Check verifies that the repository of every configured git module of
+// handler h exists and contains a go.mod file, in the subdir of the module,
+// whose module directive is the import path of the module. The repositories
+// are listed with git ls-remote and their default branch is fetched with a
+// shallow fetch. All problems are returned joined with errors.Join(), or nil
+// if every module checks out. Modules of other version control systems are
+// not checked. Domain() must be set. The handler must be created with
+// NewHandlerWithOptions.
+func Check(ctx context.Context, h http.Handler) error {
+	v, ok := h.(*handler)
+	if !ok {
+		return errors.New("vanity: check requires a handler created with NewHandlerWithOptions")
+	}
+	if v.domain == "" {
+		return errors.New("vanity: check requires a domain")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("vanity: check requires git: %w", err)
+	}
+	var errs []error
+	for _, m := range v.modules {
+		t := v.resolve(v.domain, m.Path)
+		if t.vcs != "git" {
+			continue
+		}
+		if err := checkModule(ctx, t); err != nil {
+			errs = append(errs, fmt.Errorf("vanity: module %q: %w", m.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkModule verifies that the repository of the module of t contains a
+// go.mod file of the module root of t.
+func checkModule(ctx context.Context, t *target) error {
+	if _, err := runGit(ctx, "", "ls-remote", "--exit-code", t.repoURL, "HEAD"); err != nil {
+		return fmt.Errorf("repository %v not found: %w", t.repoURL, err)
+	}
+	dir, err := os.MkdirTemp("", "vanity-check-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary repository: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := runGit(ctx, "", "init", "-q", "--bare", dir); err != nil {
+		return err
+	}
+	if _, err := runGit(ctx, dir, "fetch", "-q", "--depth", "1", t.repoURL, "HEAD"); err != nil {
+		return fmt.Errorf("error fetching repository %v: %w", t.repoURL, err)
+	}
+	name := path.Join(t.module.Subdir, "go.mod")
+	gomod, err := runGit(ctx, dir, "show", "FETCH_HEAD:"+name)
+	if err != nil {
+		return fmt.Errorf("repository %v has no %v", t.repoURL, name)
+	}
+	module := parseModuleDirective(gomod)
+	if module == "" {
+		return fmt.Errorf("%v of repository %v has no module directive", name, t.repoURL)
+	}
+	if module != t.moduleRoot {
+		return fmt.Errorf("%v of repository %v declares module %q, expected %q", name, t.repoURL, module, t.moduleRoot)
+	}
+	return nil
+}
+
+// runGit runs git with args in the bare repository dir, or in the current
+// directory if dir is empty, and returns its standard output. Credential
+// prompts are disabled.
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	command := args[0]
+	if dir != "" {
+		args = append([]string{"--git-dir", dir}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// The first line of git errors is the cause.
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		if msg != "" {
+			return nil, fmt.Errorf("git %v: %w: %v", command, err, msg)
+		}
+		return nil, fmt.Errorf("git %v: %w", command, err)
+	}
+	return out, nil
+}
+
+// parseModuleDirective returns the module path of the module directive of a
+// go.mod file.
+func parseModuleDirective(gomod []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(gomod))
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "//")
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "module" {
+			continue
+		}
+		if p, err := strconv.Unquote(fields[1]); err == nil {
+			return p
+		}
+		return fields[1]
+	}
+	return ""
+}
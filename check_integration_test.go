@@ -0,0 +1,74 @@
+package vanity_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestCheckIntegration(t *testing.T) {
+	integrationTest(t)
+	dir := t.TempDir()
+	good := gitRepo(t, map[string]string{"go.mod": "module kkn.fi/good // comment\n\ngo 1.22\n"})
+	sub := gitRepo(t, map[string]string{"sub/go.mod": "module \"kkn.fi/tools/sub\"\n"})
+	wrong := gitRepo(t, map[string]string{"go.mod": "module github.com/kare/wrong\n"})
+	nogomod := gitRepo(t, map[string]string{"README": "no module\n"})
+	missing := filepath.Join(dir, "missing.git")
+	h, err := vanity.NewHandlerWithOptions(
+		vanity.Domain("kkn.fi"),
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(
+			vanity.Module{Path: "/good", RepoURL: "file://" + good},
+			vanity.Module{Path: "/tools/sub", RepoURL: "file://" + sub, Subdir: "sub"},
+			vanity.Module{Path: "/wrong", RepoURL: "file://" + wrong},
+			vanity.Module{Path: "/nogomod", RepoURL: "file://" + nogomod},
+			vanity.Module{Path: "/missing", RepoURL: "file://" + missing},
+			vanity.Module{Path: "/hg", RepoURL: "https://hg.example.com/hg", VCS: "hg"},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vanity.Check(context.Background(), h)
+	if err == nil {
+		t.Fatal("expected check to fail")
+	}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		t.Fatalf("expected joined errors, but got %v", err)
+	}
+	errs := joined.Unwrap()
+	expected := []string{
+		`vanity: module "/wrong": go.mod of repository file://` + wrong + ` declares module "github.com/kare/wrong", expected "kkn.fi/wrong"`,
+		`vanity: module "/nogomod": repository file://` + nogomod + ` has no go.mod`,
+		`vanity: module "/missing": repository file://` + missing + ` not found: git ls-remote: exit status 128: `,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %v errors, but got %v", len(expected), err)
+	}
+	for i, e := range expected {
+		if msg := errs[i].Error(); !strings.HasPrefix(msg, e) {
+			t.Errorf("expected error %q, but got %q", e, msg)
+		}
+	}
+}
+
+func TestCheckValidIntegration(t *testing.T) {
+	integrationTest(t)
+	repos := filepath.Dir(gitRepo(t, map[string]string{"go.mod": "module kkn.fi/repo.git\n"}))
+	h, err := vanity.NewHandlerWithOptions(
+		vanity.Domain("kkn.fi"),
+		vanity.VCSURL("file://"+repos),
+		vanity.Modules(vanity.Module{Path: "/repo.git"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vanity.Check(context.Background(), h); err != nil {
+		t.Fatal(err)
+	}
+}
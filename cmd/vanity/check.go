@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"kkn.fi/vanity"
+)
+
+// check verifies the repositories of the modules configured by the
+// configuration file, environment and flags in args.
+func check(args []string) error {
+	c, err := parseConfig("vanity check", args, nil)
+	if err != nil {
+		return err
+	}
+	h, err := c.handler()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return vanity.Check(ctx, h)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"kkn.fi/vanity"
+)
+
+// envPrefix is the prefix of environment variables. The handler is
+// configured by the variables of vanity.OptionsFromEnv, such as
+// VANITY_VCS_URL, and the other flags of a command by their name, such as
+// VANITY_ADDR for -addr.
+const envPrefix = "VANITY"
+
+// moduleFlags is a repeatable flag of modules in path=repoURL form.
+type moduleFlags []vanity.Module
+
+func (f *moduleFlags) String() string {
+	if f == nil {
+		return ""
+	}
+	var s []string
+	for _, m := range *f {
+		s = append(s, m.Path+"="+m.RepoURL)
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *moduleFlags) Set(value string) error {
+	path, repoURL, _ := strings.Cut(value, "=")
+	if path == "" {
+		return fmt.Errorf("module %q has no path", value)
+	}
+	*f = append(*f, vanity.Module{Path: path, RepoURL: repoURL})
+	return nil
+}
+
+// registerConfig defines the flags of configuration c in fs.
+func registerConfig(fs *flag.FlagSet, c *vanity.ConfigFile) {
+	fs.StringVar(&c.Domain, "domain", "", "vanity domain, such as kkn.fi (default request host)")
+	fs.StringVar(&c.VCS, "vcs", "", "version control system of the repositories (default git)")
+	fs.StringVar(&c.VCSURL, "vcs-url", "", "base URL of the repositories, such as https://github.com/kare")
+	fs.StringVar(&c.ModuleServerURL, "module-server-url", "", "module server or GitHub URL browsers are redirected to; shorthand for -docs-url")
+	fs.StringVar(&c.DocsURL, "docs-url", "", "documentation URL template (default pkg.go.dev)")
+	fs.StringVar((*string)(&c.Forge), "forge", "", "forge of repositories on unknown hosts: github, gitlab, gitea or sourcehut")
+	fs.StringVar(&c.Branch, "branch", "", "default branch of tree view URLs (default main)")
+	fs.StringVar(&c.StaticDir, "static", "", "directory of static files")
+	fs.StringVar(&c.StaticURL, "static-url", "", "URL path of the static files (default /.static/)")
+	fs.StringVar(&c.IndexFile, "index", "", "HTML file served as the index page")
+	fs.StringVar(&c.IndexTemplate, "index-template", "", "template file of the generated index page")
+	fs.BoolVar(&c.Landing, "landing", false, "serve landing pages instead of redirecting to documentation")
+	fs.StringVar(&c.LandingTemplate, "landing-template", "", "template file of landing pages; implies -landing")
+	fs.BoolVar(&c.LocalDocs, "local-docs", false, "render documentation of modules with local sources")
+	fs.StringVar(&c.DocsTemplate, "docs-template", "", "template file of documentation pages; implies -local-docs")
+	fs.StringVar(&c.SearchTemplate, "search-template", "", "template file of the search page")
+	fs.StringVar(&c.RobotsTxt, "robots-txt", "", "file served as robots.txt (default generated)")
+	fs.IntVar(&c.CacheMaxAge, "cache-max-age", 0, "time in seconds responses may be cached")
+	fs.Var((*moduleFlags)(&c.Modules), "module", "module in path=repoURL form; repeatable")
+}
+
+// envName returns the name of the environment variable of flag name.
+func envName(name string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// setFromEnv sets the flags of fs from their environment variables. The
+// configuration flags are skipped, because the environment configures the
+// handler with vanity.OptionsFromEnv.
+func setFromEnv(fs *flag.FlagSet, configFlags map[string]bool) error {
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if configFlags[f.Name] {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if err := fs.Set(f.Name, v); err != nil {
+				errs = append(errs, fmt.Errorf("vanity: invalid value %q of %v: %w", v, envName(f.Name), err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// config is the configuration of a command from the file given with
+// -config, the environment and the flags, in increasing precedence.
+type config struct {
+	// file is the name of the configuration file, or empty, and
+	// fileConfig its configuration.
+	file       string
+	fileConfig *vanity.ConfigFile
+	// env are the handler options of the environment.
+	env []vanity.Option
+	// flags is the configuration of the flags given on the command line.
+	flags vanity.ConfigFile
+}
+
+// overrides returns the handler options of the environment and flags, which
+// are applied after the options of the configuration file.
+func (c *config) overrides() ([]vanity.Option, error) {
+	opts, err := c.flags.Options()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]vanity.Option(nil), c.env...), opts...), nil
+}
+
+// options returns the handler options of the configuration file, the
+// environment and the flags.
+func (c *config) options() ([]vanity.Option, error) {
+	var opts []vanity.Option
+	if c.fileConfig != nil {
+		var err error
+		if opts, err = c.fileConfig.Options(); err != nil {
+			return nil, err
+		}
+	}
+	overrides, err := c.overrides()
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, overrides...), nil
+}
+
+// handler returns a handler of the configuration with extra options opts.
+func (c *config) handler(opts ...vanity.Option) (http.Handler, error) {
+	all, err := c.options()
+	if err != nil {
+		return nil, err
+	}
+	return vanity.NewHandlerWithOptions(append(all, opts...)...)
+}
+
+// parseConfig parses the configuration of command name from the file given
+// with -config, the environment and args. Function flags defines the flags of the command
+// besides the configuration flags.
+func parseConfig(name string, args []string, flags func(fs *flag.FlagSet)) (*config, error) {
+	c, rest, err := parseConfigArgs(name, args, flags)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("vanity: %v takes no arguments", name)
+	}
+	return c, nil
+}
+
+// parseConfigArgs is like parseConfig, but returns the arguments after the
+// flags too.
+func parseConfigArgs(name string, args []string, flags func(fs *flag.FlagSet)) (*config, []string, error) {
+	c := new(config)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	registerConfig(fs, &c.flags)
+	configFlags := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		configFlags[f.Name] = true
+	})
+	fs.StringVar(&c.file, "config", "", "configuration file in JSON, or in govanityurls YAML if named *.yaml or *.yml")
+	if flags != nil {
+		flags(fs)
+	}
+	if err := setFromEnv(fs, configFlags); err != nil {
+		return nil, nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	env, err := vanity.OptionsFromEnv(envPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.env = env
+	if c.file != "" {
+		if c.fileConfig, err = vanity.ReadConfigFile(c.file); err != nil {
+			return nil, nil, err
+		}
+	}
+	return c, fs.Args(), nil
+}
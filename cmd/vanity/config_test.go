@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModuleFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "path only",
+			values: []string{"/vanity"},
+			want:   "/vanity=",
+		},
+		{
+			name:   "repeated",
+			values: []string{"/vanity", "/x=https://gitlab.com/kare/x"},
+			want:   "/vanity=,/x=https://gitlab.com/kare/x",
+		},
+		{
+			name:    "empty path",
+			values:  []string{"=https://gitlab.com/kare/x"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var f moduleFlags
+			var err error
+			for _, v := range test.values {
+				if err = f.Set(v); err != nil {
+					break
+				}
+			}
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, but got %v", test.wantErr, err)
+			}
+			if !test.wantErr && f.String() != test.want {
+				t.Errorf("expected %q, but got %q", test.want, f.String())
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "vanity.json")
+	const file = `{
+	"domain": "file.example",
+	"vcsURL": "https://github.com/file",
+	"branch": "master",
+	"landing": true,
+	"modules": [{"path": "/vanity", "description": "Vanity import paths."}]
+}`
+	if err := os.WriteFile(configFile, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	robotsTxt := filepath.Join(dir, "robots.txt")
+	if err := os.WriteFile(robotsTxt, []byte("User-agent: *\nDisallow: /\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VANITY_VCS_URL", "https://github.com/env")
+	t.Setenv("VANITY_BRANCH", "trunk")
+	t.Setenv("VANITY_MODULES", `[{"path": "/env", "repoURL": "https://gitlab.com/kare/env"}]`)
+	t.Setenv("VANITY_ADDR", ":9090")
+
+	var addr string
+	c, err := parseConfig("vanity test", []string{
+		"-config", configFile,
+		"-branch", "develop",
+		"-module", "/x=https://gitlab.com/kare/x",
+		"-robots-txt", robotsTxt,
+		"-module-server-url", "https://github.com/kare/",
+		"-cache-max-age", "60",
+	}, func(fs *flag.FlagSet) {
+		fs.StringVar(&addr, "addr", ":8080", "listen address")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.file != configFile {
+		t.Errorf("expected config file %v, but got %v", configFile, c.file)
+	}
+	if addr != ":9090" {
+		t.Errorf("expected command flag from the environment, but got %q", addr)
+	}
+	h, err := c.handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://file.example/vanity", nil))
+	if got := rec.Result().Header.Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("expected cache max age flag to set Cache-Control, but got %q", got)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		contains string
+	}{
+		{
+			name:     "environment overrides file",
+			path:     "/vanity?go-get=1",
+			contains: `<meta name="go-import" content="file.example/vanity git https://github.com/env/vanity">`,
+		},
+		{
+			name:     "flag overrides environment",
+			path:     "/vanity?go-get=1",
+			contains: "https://github.com/env/vanity/tree/develop{/dir}",
+		},
+		{
+			name:     "environment modules",
+			path:     "/env?go-get=1",
+			contains: `<meta name="go-import" content="file.example/env git https://gitlab.com/kare/env">`,
+		},
+		{
+			name:     "flag modules",
+			path:     "/x?go-get=1",
+			contains: `<meta name="go-import" content="file.example/x git https://gitlab.com/kare/x">`,
+		},
+		{
+			name:     "file landing page",
+			path:     "/vanity",
+			contains: "Vanity import paths.",
+		},
+		{
+			name:     "module server URL flag",
+			path:     "/vanity",
+			contains: `href="https://github.com/kare/vanity"`,
+		},
+		{
+			name:     "robots.txt flag",
+			path:     "/robots.txt",
+			contains: "Disallow: /\n",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "https://file.example"+test.path, nil)
+			h.ServeHTTP(rec, req)
+			body, _ := io.ReadAll(rec.Result().Body)
+			if !strings.Contains(string(body), test.contains) {
+				t.Errorf("expected body to contain %q, but got:\n%s", test.contains, body)
+			}
+		})
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "vanity.json")
+	if err := os.WriteFile(configFile, []byte(`{"domian": "kkn.fi"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "unknown config field",
+			args: []string{"-config", configFile},
+		},
+		{
+			name: "missing config file",
+			args: []string{"-config", filepath.Join(dir, "missing.json")},
+		},
+		{
+			name: "arguments",
+			args: []string{"serve"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := parseConfig("vanity test", test.args, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
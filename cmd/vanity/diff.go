@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"kkn.fi/vanity"
+)
+
+// errResponsesDiffer is returned by diff when the responses of the
+// configurations differ.
+var errResponsesDiffer = errors.New("vanity: responses differ")
+
+// diff prints the changes of the responses from the old to the new
+// configuration file in args over the known paths of both.
+func diff(args []string) error {
+	fs := flag.NewFlagSet("vanity diff", flag.ContinueOnError)
+	domain := fs.String("domain", "", "domain of configuration files without one")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: vanity diff [flags] old.json new.json\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("vanity: vanity diff takes two configuration files")
+	}
+	var handlers [2]http.Handler
+	for i, name := range fs.Args() {
+		c, err := vanity.ReadConfigFile(name)
+		if err != nil {
+			return err
+		}
+		if c.Domain == "" {
+			c.Domain = *domain
+		}
+		opts, err := c.Options()
+		if err != nil {
+			return err
+		}
+		h, err := vanity.NewHandlerWithOptions(opts...)
+		if err != nil {
+			return fmt.Errorf("vanity: %v: %w", name, err)
+		}
+		handlers[i] = h
+	}
+	diffs, err := vanity.DiffHandlers(handlers[0], handlers[1])
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		fmt.Printf("# %v GET %v\n%v", d.Client, d.URL, d.Diff)
+	}
+	if len(diffs) > 0 {
+		return errResponsesDiffer
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"kkn.fi/vanity"
+)
+
+// export writes a static site, or redirect rules, of the vanity import paths
+// configured by the configuration file, environment and flags in args.
+func export(args []string) error {
+	var out, format string
+	c, err := parseConfig("vanity export", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&out, "out", "site", "output directory")
+		fs.StringVar(&format, "format", "site", "export format: site, caddy, nginx or redirects")
+	})
+	if err != nil {
+		return err
+	}
+	h, err := c.handler()
+	if err != nil {
+		return err
+	}
+	if format == "site" {
+		return vanity.Export(h, out)
+	}
+	f := vanity.RuleFormat(format)
+	rules, err := vanity.RedirectRules(h, f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return fmt.Errorf("vanity: error creating output directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(out, f.FileName()), rules, 0o644); err != nil {
+		return fmt.Errorf("vanity: error writing redirect rules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Command vanity serves and exports Go vanity import paths.
+//
+// Usage:
+//
+//	vanity <command> [flags]
+//
+// The commands are:
+//
+//	serve     run the vanity server
+//	export    write a static site of the vanity import paths
+//	resolve   print the responses to import paths without serving them
+//	diff      compare the responses of two configuration files
+//	check     verify the repositories of the modules
+//
+// Run vanity <command> -h for the flags of a command. The handler is also
+// configured by the environment variables of vanity.OptionsFromEnv with
+// prefix VANITY, such as VANITY_VCS_URL and VANITY_MODULES, and by the JSON
+// configuration file given with -config:
+//
+//	{
+//		"domain": "kkn.fi",
+//		"vcsURL": "https://github.com/kare",
+//		"landing": true,
+//		"modules": [
+//			{"path": "/vanity", "description": "Vanity import paths."},
+//			{"path": "/x", "repoURL": "https://gitlab.com/kare/x"}
+//		]
+//	}
+//
+// Other flags of a command are set by an environment variable of their
+// name, such as VANITY_ADDR for -addr. Flags take precedence over
+// environment variables, which take precedence over the configuration file.
+// VANITY_ROBOTS_TXT is the contents of robots.txt, whereas -robots-txt and
+// VANITY_ROBOTS_TXT_FILE name a file.
+// Modules given with -module or VANITY_MODULES are added to the modules of
+// the configuration file. See vanity.ConfigFile for all fields.
+// With vanity serve -watch, the configuration file is reloaded when it
+// changes. An invalid configuration is logged and the previous one is kept
+// serving.
+//
+// vanity serve -hosts serves several domains from a directory of
+// configuration files named by their domain, such as kkn.fi.json and
+// go.example.com.yaml. Flags and environment variables apply to every
+// domain, and with -watch files are added, removed and reloaded one domain
+// at a time.
+//
+// vanity serve -admin-addr runs the admin API of the -config file on a
+// separate listener. Requests are authenticated with the bearer token of
+// -admin-token or VANITY_ADMIN_TOKEN, or of the -admin-tokens file of tokens
+// limited to module path prefixes. Changes are appended to the -admin-audit
+// file. See vanity.Admin.
+//
+// vanity serve -history appends every accepted revision of the -config file,
+// reloaded or written by the admin API, to a history file. The admin API
+// lists the revisions, shows their differences and rolls back to a previous
+// revision. See vanity.History.
+//
+// vanity resolve prints the status, headers and body of the responses to the
+// go tool and to a browser requesting import paths, such as
+//
+//	vanity resolve -config vanity.json kkn.fi/x/sub
+//
+// vanity diff compares two configuration files over the known paths of both
+// and prints the changed responses as unified diffs. It exits with status 1
+// if any response changes. See vanity.Resolve and vanity.DiffHandlers.
+//
+// vanity check verifies that the repository of every git module exists and
+// contains a go.mod file whose module directive is the import path of the
+// module. All problems are printed and the exit status is 1. See
+// vanity.Check.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// commands maps command names to their implementations. A command parses
+// its flags from args.
+var commands = map[string]func(args []string) error{
+	"serve":   serve,
+	"export":  export,
+	"resolve": resolve,
+	"diff":    diff,
+	"check":   check,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: vanity <command> [flags]\n\ncommands:\n")
+	fmt.Fprintf(os.Stderr, "  serve     run the vanity server\n")
+	fmt.Fprintf(os.Stderr, "  export    write a static site of the vanity import paths\n")
+	fmt.Fprintf(os.Stderr, "  resolve   print the responses to import paths without serving them\n")
+	fmt.Fprintf(os.Stderr, "  diff      compare the responses of two configuration files\n")
+	fmt.Fprintf(os.Stderr, "  check     verify the repositories of the modules\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "vanity: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
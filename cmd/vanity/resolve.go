@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"kkn.fi/vanity"
+)
+
+// resolve prints the responses of the handler configured by the
+// configuration file, environment and flags in args to the go tool and to a
+// browser requesting the import paths after the flags.
+func resolve(args []string) error {
+	c, paths, err := parseConfigArgs("vanity resolve", args, nil)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("vanity: vanity resolve takes import paths")
+	}
+	h, err := c.handler()
+	if err != nil {
+		return err
+	}
+	for i, p := range paths {
+		responses, err := vanity.Resolve(h, p)
+		if err != nil {
+			return err
+		}
+		for j, res := range responses {
+			if i > 0 || j > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("# %v GET %v\n%v", res.Client, res.URL, res)
+			if n := len(res.Body); n > 0 && res.Body[n-1] != '\n' {
+				fmt.Println()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"kkn.fi/vanity"
+)
+
+// Server timeouts.
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 10 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+	shutdownTimeout   = 15 * time.Second
+)
+
+// serve runs the vanity server configured by the configuration file,
+// environment and flags in args until it receives SIGINT or SIGTERM. With
+// -watch the configuration file is reloaded when it changes. With -hosts
+// every configuration file of a directory serves its own domain, and the
+// environment and flags apply to all of them. With -admin-addr the admin API
+// of the configuration file listens on a separate address.
+func serve(args []string) error {
+	const name = "vanity serve"
+	var addr string
+	var watch time.Duration
+	var hosts string
+	var adminAddr, adminToken, adminTokens, adminAudit string
+	var history string
+	flags := func(fs *flag.FlagSet) {
+		fs.StringVar(&addr, "addr", ":8080", "listen address of the server")
+		fs.DurationVar(&watch, "watch", 0, "interval of polling the -config file or -hosts directory for changes; 0 disables reloading")
+		fs.StringVar(&hosts, "hosts", "", "directory of configuration files named by their domain, such as kkn.fi.json")
+		fs.StringVar(&adminAddr, "admin-addr", "", "listen address of the admin API managing the modules of the -config file")
+		fs.StringVar(&adminToken, "admin-token", "", "bearer token of the admin API with access to all modules")
+		fs.StringVar(&adminTokens, "admin-tokens", "", "JSON file of admin API tokens limited to module path prefixes")
+		fs.StringVar(&adminAudit, "admin-audit", "", "file the changes of the admin API are appended to")
+		fs.StringVar(&history, "history", "", "file the accepted revisions of the -config file are appended to")
+	}
+	c, err := parseConfig(name, args, flags)
+	if err != nil {
+		return err
+	}
+	configFile := c.file
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger := log.New(os.Stderr, "", log.LstdFlags|log.LUTC)
+	if adminAddr != "" && configFile == "" {
+		return errors.New("vanity: -admin-addr requires -config")
+	}
+	if history != "" && configFile == "" {
+		return errors.New("vanity: -history requires -config")
+	}
+	var h, admin http.Handler
+	switch {
+	case hosts != "":
+		if configFile != "" {
+			return errors.New("vanity: -hosts and -config are mutually exclusive")
+		}
+		// Every host has the domain of its configuration file.
+		if c.flags.Domain != "" || os.Getenv(envName("domain")) != "" {
+			return errors.New("vanity: -domain can't be used with -hosts")
+		}
+		opts, err := c.overrides()
+		if err != nil {
+			return err
+		}
+		hs, err := vanity.NewHosts(hosts, nil, append(opts, vanity.Log(logger))...)
+		if err != nil {
+			return err
+		}
+		if watch > 0 {
+			go hs.Watch(ctx, watch)
+		}
+		h = hs
+	case configFile != "" && (watch > 0 || adminAddr != "" || history != ""):
+		// Environment and flags are applied to every reloaded
+		// configuration.
+		opts, err := c.overrides()
+		if err != nil {
+			return err
+		}
+		r, err := vanity.NewReloader(configFile, nil, append(opts, vanity.Log(logger))...)
+		if err != nil {
+			return err
+		}
+		if history != "" {
+			hist, err := vanity.OpenHistory(history)
+			if err != nil {
+				return err
+			}
+			defer hist.Close()
+			r.SetHistory(hist)
+		}
+		if watch > 0 {
+			go r.Watch(ctx, watch)
+		}
+		if adminAddr != "" {
+			tokens, err := readAdminTokens(adminToken, adminTokens)
+			if err != nil {
+				return err
+			}
+			var audit io.Writer
+			if adminAudit != "" {
+				f, err := os.OpenFile(adminAudit, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+				if err != nil {
+					return fmt.Errorf("vanity: error opening audit log: %w", err)
+				}
+				defer f.Close()
+				audit = f
+			}
+			if admin, err = vanity.NewAdmin(r, tokens, audit); err != nil {
+				return err
+			}
+		}
+		h = r
+	default:
+		h, err = c.handler(vanity.Log(logger))
+		if err != nil {
+			return err
+		}
+	}
+	servers := []*http.Server{newServer(addr, h, logger)}
+	if admin != nil {
+		servers = append(servers, newServer(adminAddr, admin, logger))
+	}
+	errc := make(chan error, len(servers))
+	for _, srv := range servers {
+		srv := srv
+		go func() {
+			logger.Printf("vanity: listening on %v", srv.Addr)
+			errc <- srv.ListenAndServe()
+		}()
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	logger.Printf("vanity: shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	for range servers {
+		if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAdminTokens returns the admin API tokens of the -admin-token flag and
+// the JSON file name of the -admin-tokens flag.
+//
+//	[
+//		{"name": "payments", "token": "secret", "prefix": "/payments"}
+//	]
+func readAdminTokens(token, name string) ([]vanity.AdminToken, error) {
+	var tokens []vanity.AdminToken
+	if name != "" {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("vanity: error reading admin tokens: %w", err)
+		}
+		if err := json.Unmarshal(b, &tokens); err != nil {
+			return nil, fmt.Errorf("vanity: error parsing admin tokens %v: %w", name, err)
+		}
+	}
+	if token != "" {
+		tokens = append(tokens, vanity.AdminToken{Name: "admin", Token: token})
+	}
+	return tokens, nil
+}
+
+// newServer returns a server of h listening on addr with timeouts.
+func newServer(addr string, h http.Handler, logger *log.Logger) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		ErrorLog:          logger,
+	}
+}
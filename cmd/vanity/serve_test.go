@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadAdminTokens(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(name, []byte(`[{"name": "payments", "token": "p", "prefix": "/payments"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := readAdminTokens("secret", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 tokens, but got %v", tokens)
+	}
+	if tokens[0].Name != "payments" || tokens[0].Token != "p" || tokens[0].Prefix != "/payments" {
+		t.Errorf("unexpected token %+v", tokens[0])
+	}
+	if tokens[1].Name != "admin" || tokens[1].Token != "secret" || tokens[1].Prefix != "" {
+		t.Errorf("unexpected token %+v", tokens[1])
+	}
+	if _, err := readAdminTokens("", name+".missing"); err == nil {
+		t.Error("expected an error of a missing tokens file")
+	}
+}
@@ -0,0 +1,250 @@
+package vanity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ConfigFile is the JSON configuration file of a handler. Empty fields use
+// the defaults of NewHandlerWithOptions. Relative file and directory paths
+// in a configuration file are relative to the directory of the file.
+//
+//	{
+//		"domain": "kkn.fi",
+//		"vcsURL": "https://github.com/kare",
+//		"docsURL": "https://pkg.go.dev/{importPath}{@version}",
+//		"staticDir": "static",
+//		"landing": true,
+//		"modules": [
+//			{"path": "/vanity", "description": "Vanity import paths."},
+//			{"path": "/x", "repoURL": "https://gitlab.com/kare/x"}
+//		]
+//	}
+type ConfigFile struct {
+	// Domain sets Domain().
+	Domain string `json:"domain,omitempty"`
+	// VCS sets VCS().
+	VCS string `json:"vcs,omitempty"`
+	// VCSURL sets VCSURL().
+	VCSURL string `json:"vcsURL,omitempty"`
+	// ModuleServerURL sets ModuleServerURL(). DocsURL takes precedence.
+	ModuleServerURL string `json:"moduleServerURL,omitempty"`
+	// DocsURL sets DocsURL().
+	DocsURL string `json:"docsURL,omitempty"`
+	// Forge sets DefaultForge().
+	Forge Forge `json:"forge,omitempty"`
+	// Branch sets DefaultBranch().
+	Branch string `json:"branch,omitempty"`
+	// StaticDir and StaticURL set StaticDir(). StaticURL defaults to
+	// /.static/.
+	StaticDir string `json:"staticDir,omitempty"`
+	StaticURL string `json:"staticURL,omitempty"`
+	// IndexFile is an HTML file served as the index page with
+	// DefaultIndexPageHandler().
+	IndexFile string `json:"indexFile,omitempty"`
+	// IndexTemplate is a template file of the generated index page. See
+	// IndexTemplate().
+	IndexTemplate string `json:"indexTemplate,omitempty"`
+	// Landing enables landing pages. LandingTemplate is a template file of
+	// the landing pages and implies Landing. See LandingPage().
+	Landing         bool   `json:"landing,omitempty"`
+	LandingTemplate string `json:"landingTemplate,omitempty"`
+	// LocalDocs enables local documentation. DocsTemplate is a template file
+	// of the documentation pages and implies LocalDocs. See LocalDocs().
+	LocalDocs    bool   `json:"localDocs,omitempty"`
+	DocsTemplate string `json:"docsTemplate,omitempty"`
+	// SearchTemplate is a template file of the search page. See
+	// SearchTemplate().
+	SearchTemplate string `json:"searchTemplate,omitempty"`
+	// RobotsTxt is a file served as robots.txt. See RobotsTxt().
+	RobotsTxt string `json:"robotsTxt,omitempty"`
+	// CacheMaxAge is the time in seconds responses may be cached. See
+	// CacheMaxAge().
+	CacheMaxAge int `json:"cacheMaxAge,omitempty"`
+	// Modules sets Modules().
+	Modules []Module `json:"modules,omitempty"`
+}
+
+// defaultStaticURL is the URL path of the static directory of configuration
+// files.
+const defaultStaticURL = "/.static/"
+
+// ReadConfigFile reads and parses the JSON configuration file name. Unknown
+// fields are errors. Files with a .yaml or .yml extension are parsed as
+// govanityurls configuration files with ParseGovanityurls().
+func ReadConfigFile(name string) (*ConfigFile, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("vanity: error reading config file: %w", err)
+	}
+	c, err := parseConfigFile(name, b)
+	if err != nil {
+		return nil, err
+	}
+	c.resolvePaths(filepath.Dir(name))
+	return c, nil
+}
+
+// isGovanityurls reports whether the configuration file name is in the
+// govanityurls format.
+func isGovanityurls(name string) bool {
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// parseConfigFile parses the contents b of the configuration file name
+// without resolving its relative paths.
+func parseConfigFile(name string, b []byte) (*ConfigFile, error) {
+	if isGovanityurls(name) {
+		return ParseGovanityurls(b)
+	}
+	c := new(ConfigFile)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("vanity: error parsing config file %v: %w", name, err)
+	}
+	return c, nil
+}
+
+// resolvePaths makes the relative paths of c relative to dir.
+func (c *ConfigFile) resolvePaths(dir string) {
+	resolve := func(p *string) {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	for _, p := range []*string{
+		&c.StaticDir,
+		&c.IndexFile,
+		&c.IndexTemplate,
+		&c.LandingTemplate,
+		&c.DocsTemplate,
+		&c.SearchTemplate,
+		&c.RobotsTxt,
+	} {
+		resolve(p)
+	}
+	for i := range c.Modules {
+		resolve(&c.Modules[i].SourceDir)
+		resolve(&c.Modules[i].RepoDir)
+	}
+}
+
+// parseTemplateFile parses the template file name, or returns nil if name
+// is empty.
+func parseTemplateFile(name string) (*template.Template, error) {
+	if name == "" {
+		return nil, nil
+	}
+	tmpl, err := template.ParseFiles(name)
+	if err != nil {
+		return nil, fmt.Errorf("vanity: error parsing template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Options returns the handler options of the configuration. Template and
+// robots.txt files are read.
+func (c *ConfigFile) Options() ([]Option, error) {
+	var opts []Option
+	if c.Domain != "" {
+		opts = append(opts, Domain(c.Domain))
+	}
+	if c.VCS != "" {
+		opts = append(opts, VCS(c.VCS))
+	}
+	if c.VCSURL != "" {
+		opts = append(opts, VCSURL(c.VCSURL))
+	}
+	if c.ModuleServerURL != "" {
+		opts = append(opts, ModuleServerURL(c.ModuleServerURL))
+	}
+	if c.DocsURL != "" {
+		opts = append(opts, DocsURL(c.DocsURL))
+	}
+	if c.Forge != "" {
+		opts = append(opts, DefaultForge(c.Forge))
+	}
+	if c.Branch != "" {
+		opts = append(opts, DefaultBranch(c.Branch))
+	}
+	if c.StaticDir != "" {
+		staticURL := c.StaticURL
+		if staticURL == "" {
+			staticURL = defaultStaticURL
+		}
+		opts = append(opts, StaticDir(c.StaticDir, staticURL))
+	}
+	if c.CacheMaxAge != 0 {
+		opts = append(opts, CacheMaxAge(time.Duration(c.CacheMaxAge)*time.Second))
+	}
+	if c.IndexFile != "" {
+		opts = append(opts, IndexPageHandler(DefaultIndexPageHandler(c.IndexFile)))
+	}
+	if c.RobotsTxt != "" {
+		b, err := os.ReadFile(c.RobotsTxt)
+		if err != nil {
+			return nil, fmt.Errorf("vanity: error reading robots.txt: %w", err)
+		}
+		opts = append(opts, RobotsTxt(string(b)))
+	}
+	// Template options are used if the template file is given or the
+	// feature is enabled with the built-in template.
+	templates := []struct {
+		name    string
+		enabled bool
+		option  func(*template.Template) Option
+	}{
+		{c.IndexTemplate, false, func(t *template.Template) Option { return IndexTemplate(t, nil) }},
+		{c.LandingTemplate, c.Landing, LandingPage},
+		{c.DocsTemplate, c.LocalDocs, LocalDocs},
+		{c.SearchTemplate, false, SearchTemplate},
+	}
+	for _, t := range templates {
+		if t.name == "" && !t.enabled {
+			continue
+		}
+		tmpl, err := parseTemplateFile(t.name)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, t.option(tmpl))
+	}
+	if len(c.Modules) > 0 {
+		opts = append(opts, Modules(c.Modules...))
+	}
+	return opts, nil
+}
+
+// LoadFile returns a handler configured by the JSON configuration file name.
+// Given options are applied after the options of the file. See ConfigFile.
+func LoadFile(name string, opts ...Option) (http.Handler, error) {
+	c, err := ReadConfigFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.handler(opts...)
+}
+
+// handler returns a handler configured by c and opts.
+func (c *ConfigFile) handler(opts ...Option) (*handler, error) {
+	fileOpts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	h, err := NewHandlerWithOptions(append(fileOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return h.(*handler), nil
+}
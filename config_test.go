@@ -0,0 +1,143 @@
+package vanity_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func writeConfigFile(t *testing.T, dir, config string) string {
+	t.Helper()
+	name := filepath.Join(dir, "vanity.json")
+	if err := os.WriteFile(name, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func goImport(t *testing.T, h http.Handler, path string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, addr+path+"?go-get=1", nil)
+	h.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Result().Body)
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.Contains(line, `name="go-import"`) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "robots.txt"), []byte("robots are here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	name := writeConfigFile(t, dir, `{
+	"domain": "kkn.fi",
+	"vcsURL": "https://github.com/kare",
+	"robotsTxt": "robots.txt",
+	"landing": true,
+	"modules": [
+		{"path": "/vanity", "description": "Vanity import paths."},
+		{"path": "/x", "repoURL": "https://gitlab.com/kare/x", "state": "deprecated"}
+	]
+}`)
+	srv, err := vanity.LoadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		contains []string
+	}{
+		{
+			name: "module",
+			path: "/vanity",
+			contains: []string{
+				`<meta name="go-import" content="kkn.fi/vanity git https://github.com/kare/vanity">`,
+				`<p>Vanity import paths.</p>`,
+			},
+		},
+		{
+			name: "module with repository",
+			path: "/x",
+			contains: []string{
+				`<meta name="go-import" content="kkn.fi/x git https://gitlab.com/kare/x">`,
+				`<strong>Deprecated:</strong>`,
+			},
+		},
+		{
+			name: "robots.txt relative to config file",
+			path: "/robots.txt",
+			contains: []string{
+				`robots are here`,
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "https://example.com"+test.path, nil)
+			srv.ServeHTTP(rec, req)
+			body, _ := io.ReadAll(rec.Result().Body)
+			for _, s := range test.contains {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("expected body to contain %q, but got:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "unknown field",
+			config: `{"domian": "kkn.fi"}`,
+			err:    `json: unknown field "domian"`,
+		},
+		{
+			name:   "syntax",
+			config: `{"domain": }`,
+			err:    `invalid character`,
+		},
+		{
+			name:   "invalid option",
+			config: `{"forge": "bitbucket"}`,
+			err:    `vanity: unknown forge "bitbucket"`,
+		},
+		{
+			name:   "missing template",
+			config: `{"landingTemplate": "missing.html"}`,
+			err:    `vanity: error parsing template`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			name := writeConfigFile(t, t.TempDir(), test.config)
+			_, err := vanity.LoadFile(name)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing %q, but got %v", test.err, err)
+			}
+		})
+	}
+}
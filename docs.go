@@ -0,0 +1,351 @@
+package vanity
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultDocsTemplate is the built-in package documentation template. It is
+// executed with a *DocsPage.
+var DefaultDocsTemplate = templates.Lookup("docs.html")
+
+// localDocsURL is the documentation URL template of modules whose
+// documentation is rendered by the handler. The {scheme} placeholder is
+// internal to the handler and isn't accepted in configured templates.
+const localDocsURL = "{scheme}://{importPath}"
+
+// DocsPage describes the documentation of a package rendered from local
+// sources.
+type DocsPage struct {
+	*ModulePage
+	// Name is the package name. Name is empty if the directory contains only
+	// sub-packages.
+	Name     string
+	Synopsis string
+	Doc      template.HTML
+	Consts   []DocsDecl
+	Vars     []DocsDecl
+	Funcs    []DocsDecl
+	Types    []DocsType
+	Examples []DocsExample
+	// Packages lists the sub-packages of the package directory.
+	Packages []DocsPackage
+}
+
+// DocsDecl is a documented declaration.
+type DocsDecl struct {
+	Name     string
+	Decl     string
+	Doc      template.HTML
+	Examples []DocsExample
+}
+
+// DocsType is a documented type with its associated declarations.
+type DocsType struct {
+	DocsDecl
+	Consts  []DocsDecl
+	Vars    []DocsDecl
+	Funcs   []DocsDecl
+	Methods []DocsDecl
+}
+
+// DocsExample is an example function of a package.
+type DocsExample struct {
+	Name   string
+	Doc    string
+	Code   string
+	Output string
+}
+
+// DocsPackage is an entry of the sub-package index.
+type DocsPackage struct {
+	// Path is the path of the package on the vanity server.
+	Path       string
+	ImportPath string
+	Synopsis   string
+}
+
+// errNoPackage is returned when a directory doesn't exist or isn't a
+// package directory.
+var errNoPackage = errors.New("vanity: no package in directory")
+
+// sourceDir returns the local directory of the package of t.
+func (t *target) sourceDir() (string, error) {
+	if t.module == nil || t.module.SourceDir == "" {
+		return "", errNoPackage
+	}
+	for _, c := range strings.Split(t.subPath, "/") {
+		if c == ".." || strings.HasPrefix(c, ".") || strings.HasPrefix(c, "_") || c == "testdata" {
+			return "", errNoPackage
+		}
+	}
+	dir := filepath.Join(t.module.SourceDir, filepath.FromSlash(t.subPath))
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return "", errNoPackage
+	}
+	return dir, nil
+}
+
+// docsPage parses the package sources of t and returns its documentation.
+func (h *handler) docsPage(t *target) (*DocsPage, error) {
+	dir, err := t.sourceDir()
+	if err != nil {
+		return nil, err
+	}
+	p := &DocsPage{
+		ModulePage: h.modulePage(t),
+	}
+	pkg, fset, err := parsePackage(dir, t.importPath)
+	if err != nil && !errors.Is(err, errNoPackage) {
+		return nil, err
+	}
+	p.Packages, err = subPackages(dir, t.path, t.importPath)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == nil {
+		if len(p.Packages) == 0 {
+			return nil, errNoPackage
+		}
+		return p, nil
+	}
+	d := &docsPrinter{pkg: pkg, fset: fset}
+	p.Name = pkg.Name
+	p.Synopsis = pkg.Synopsis(pkg.Doc)
+	p.Doc = d.html(pkg.Doc)
+	p.Consts = d.values(pkg.Consts)
+	p.Vars = d.values(pkg.Vars)
+	p.Funcs = d.funcs(pkg.Funcs)
+	for _, typ := range pkg.Types {
+		p.Types = append(p.Types, DocsType{
+			DocsDecl: DocsDecl{
+				Name:     typ.Name,
+				Decl:     d.node(typ.Decl),
+				Doc:      d.html(typ.Doc),
+				Examples: d.examples(typ.Examples),
+			},
+			Consts:  d.values(typ.Consts),
+			Vars:    d.values(typ.Vars),
+			Funcs:   d.funcs(typ.Funcs),
+			Methods: d.funcs(typ.Methods),
+		})
+	}
+	p.Examples = d.examples(pkg.Examples)
+	return p, nil
+}
+
+// parsePackage parses the Go files of dir matching the default build
+// context, including tests for examples.
+func parsePackage(dir, importPath string) (*doc.Package, *token.FileSet, error) {
+	bp, err := build.Default.ImportDir(dir, build.ImportComment)
+	if err != nil {
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) {
+			return nil, nil, errNoPackage
+		}
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	names := append(append(append([]string{}, bp.GoFiles...), bp.TestGoFiles...), bp.XTestGoFiles...)
+	for _, name := range names {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	pkg, err := doc.NewFromFiles(fset, files, importPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg, fset, nil
+}
+
+// subPackages returns the packages in the sub-directories of dir. Nested
+// modules, testdata and directories ignored by the go tool are skipped.
+func subPackages(dir, urlPath, importPath string) ([]DocsPackage, error) {
+	var pkgs []DocsPackage
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == dir {
+			return nil
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+			return filepath.SkipDir
+		}
+		bp, err := build.Default.ImportDir(p, build.ImportComment)
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		pkgs = append(pkgs, DocsPackage{
+			Path:       path.Join(urlPath, rel),
+			ImportPath: importPath + "/" + rel,
+			Synopsis:   packageSynopsis(p, bp.GoFiles),
+		})
+		return nil
+	})
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].ImportPath < pkgs[j].ImportPath
+	})
+	return pkgs, err
+}
+
+// packageSynopsis returns the synopsis of the package comment found in the
+// given files of dir.
+func packageSynopsis(dir string, files []string) string {
+	fset := token.NewFileSet()
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil || f.Doc == nil {
+			continue
+		}
+		return new(doc.Package).Synopsis(f.Doc.Text())
+	}
+	return ""
+}
+
+// docsPrinter formats documentation of a package for HTML templates.
+type docsPrinter struct {
+	pkg  *doc.Package
+	fset *token.FileSet
+}
+
+func (d *docsPrinter) html(text string) template.HTML {
+	return template.HTML(d.pkg.HTML(text))
+}
+
+func (d *docsPrinter) node(node interface{}) string {
+	var buf bytes.Buffer
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := config.Fprint(&buf, d.fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+func (d *docsPrinter) values(values []*doc.Value) []DocsDecl {
+	var decls []DocsDecl
+	for _, v := range values {
+		decls = append(decls, DocsDecl{
+			Name: strings.Join(v.Names, ", "),
+			Decl: d.node(v.Decl),
+			Doc:  d.html(v.Doc),
+		})
+	}
+	return decls
+}
+
+func (d *docsPrinter) funcs(funcs []*doc.Func) []DocsDecl {
+	var decls []DocsDecl
+	for _, f := range funcs {
+		name := f.Name
+		if f.Recv != "" {
+			name = strings.TrimPrefix(f.Recv, "*") + "." + f.Name
+		}
+		decls = append(decls, DocsDecl{
+			Name:     name,
+			Decl:     d.node(f.Decl),
+			Doc:      d.html(f.Doc),
+			Examples: d.examples(f.Examples),
+		})
+	}
+	return decls
+}
+
+func (d *docsPrinter) examples(examples []*doc.Example) []DocsExample {
+	var result []DocsExample
+	for _, e := range examples {
+		code := d.node(e.Code)
+		if _, ok := e.Code.(*ast.BlockStmt); ok {
+			code = unindentBlock(code)
+		}
+		result = append(result, DocsExample{
+			Name:   e.Name,
+			Doc:    e.Doc,
+			Code:   code,
+			Output: e.Output,
+		})
+	}
+	return result
+}
+
+// unindentBlock strips the braces and one level of indentation of a
+// formatted block statement.
+func unindentBlock(code string) string {
+	code = strings.TrimSpace(code)
+	code = strings.TrimPrefix(code, "{")
+	code = strings.TrimSuffix(code, "}")
+	lines := strings.Split(strings.Trim(code, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "\t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// serveDocs renders the documentation of t. It reports false if the module of
+// t has no local sources.
+func (h *handler) serveDocs(w http.ResponseWriter, r *http.Request, t *target) bool {
+	if h.docsTemplate == nil {
+		return false
+	}
+	page, err := h.docsPage(t)
+	if errors.Is(err, errNoPackage) {
+		if t.module == nil || t.module.SourceDir == "" {
+			return false
+		}
+		http.NotFound(w, r)
+		return true
+	}
+	if err != nil {
+		h.log.Printf("vanity: error rendering documentation of %v: %v", t.importPath, err)
+		status := http.StatusInternalServerError
+		http.Error(w, http.StatusText(status), status)
+		return true
+	}
+	h.render(w, h.docsTemplate, page)
+	return true
+}
+
+// LocalDocs renders package documentation for modules with a SourceDir
+// instead of redirecting browsers to the documentation URL. Documentation is
+// parsed from the local sources with go/doc on each request. If tmpl is nil,
+// DefaultDocsTemplate is used. The template is executed with a *DocsPage.
+// Links to the documentation use the scheme of the request, or of the
+// X-Forwarded-Proto header behind a reverse proxy.
+func LocalDocs(tmpl *template.Template) Option {
+	return func(h http.Handler) error {
+		v := h.(*handler)
+		if tmpl == nil {
+			tmpl = DefaultDocsTemplate
+		}
+		v.config.DocsTemplate = tmpl
+		return nil
+	}
+}
@@ -0,0 +1,190 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestLocalDocs(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{
+			name:   "package",
+			path:   "/greet",
+			status: http.StatusOK,
+			contains: []string{
+				`<title>greet package - kkn.fi/greet</title>`,
+				`<h1>package greet</h1>`,
+				`<p>Package greet says hello. It exists for testing documentation rendering.`,
+				`<pre>const Greeting = &#34;Hello&#34;</pre>`,
+				`<pre>var Loud = false</pre>`,
+				`<pre>func Hello(name string) string</pre>`,
+				`<h3>type Greeter</h3>`,
+				`// Greeting used by the greeter.`,
+				`<pre>func NewGreeter(greeting string) *Greeter</pre>`,
+				`<h3>Greeter.Greet</h3>`,
+				`<summary>Example (Hello)</summary>`,
+				`fmt.Println(greet.Hello(&#34;Gopher&#34;))`,
+				`<pre>Hello, Gopher!`,
+				`<dt><a href="/greet/sub">kkn.fi/greet/sub</a></dt>`,
+				`<dd>Package sub is a sub-package of greet.</dd>`,
+				`<dt><a href="/greet/sub/inner">kkn.fi/greet/sub/inner</a></dt>`,
+			},
+			excludes: []string{
+				`secret`,
+				`return fmt.Sprintf`,
+				`kkn.fi/greet/testdata`,
+				`kkn.fi/greet/nested`,
+			},
+		},
+		{
+			name:   "sub-package",
+			path:   "/greet/sub/",
+			status: http.StatusOK,
+			contains: []string{
+				`<h1>package sub</h1>`,
+				`<pre>import "kkn.fi/greet/sub"</pre>`,
+				`<dt><a href="/greet/sub/inner">kkn.fi/greet/sub/inner</a></dt>`,
+			},
+		},
+		{
+			name:   "package not found",
+			path:   "/greet/missing",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "testdata is not a package",
+			path:   "/greet/testdata",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "parent directory",
+			path:   "/greet/../docs",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "module without sources redirects",
+			path:   "/vanity",
+			status: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv, err := vanity.NewHandlerWithOptions(
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(vanity.Module{
+					Path:      "/greet",
+					SourceDir: "testdata/docs/greet",
+				}),
+				vanity.LocalDocs(nil),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != test.status {
+				t.Errorf("expected response status %v, but got %v", test.status, res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			for _, s := range test.contains {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("expecting\n%v be contained in\n%s", s, body)
+				}
+			}
+			for _, s := range test.excludes {
+				if strings.Contains(string(body), s) {
+					t.Errorf("expecting\n%v not be contained in\n%s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalDocsURL(t *testing.T) {
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(vanity.Module{
+			Path:      "/greet",
+			SourceDir: "testdata/docs/greet",
+		}),
+		vanity.LocalDocs(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		url      string
+		header   string
+		expected string
+	}{
+		{
+			name:     "https",
+			url:      addr + "/",
+			expected: `<a href="https://kkn.fi/greet">Documentation</a>`,
+		},
+		{
+			name:     "http",
+			url:      "http://kkn.fi/",
+			expected: `<a href="http://kkn.fi/greet">Documentation</a>`,
+		},
+		{
+			name:     "forwarded proto",
+			url:      "http://kkn.fi/",
+			header:   "https",
+			expected: `<a href="https://kkn.fi/greet">Documentation</a>`,
+		},
+		{
+			name:     "go tool over http",
+			url:      "http://kkn.fi/greet?go-get=1",
+			expected: `<meta http-equiv="refresh" content="0; url=http://kkn.fi/greet">`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			if test.header != "" {
+				req.Header.Set("X-Forwarded-Proto", test.header)
+			}
+			srv.ServeHTTP(rec, req)
+			body, _ := io.ReadAll(rec.Result().Body)
+			if !strings.Contains(string(body), test.expected) {
+				t.Errorf("expecting\n%v be contained in\n%s", test.expected, body)
+			}
+		})
+	}
+}
+
+func TestLocalDocsSourceDirInvalid(t *testing.T) {
+	_, err := vanity.NewHandlerWithOptions(
+		vanity.Modules(vanity.Module{
+			Path:      "/greet",
+			SourceDir: "testdata/index.html",
+		}),
+	)
+	if err == nil {
+		t.Error("expecting error, but got nil")
+	}
+}
@@ -0,0 +1,92 @@
+package vanity
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Documentation URL template presets for DocsURL().
+const (
+	// DocsPkgGoDev redirects browsers to the Go module server by Google.
+	DocsPkgGoDev = "https://pkg.go.dev/{importPath}{@version}"
+	// DocsGodocsIO redirects browsers to godocs.io.
+	DocsGodocsIO = "https://godocs.io/{importPath}{@version}"
+	// DocsSourcegraph redirects browsers to the package directory on
+	// Sourcegraph.
+	DocsSourcegraph = "https://sourcegraph.com/{repo}{@version}/-/tree/{subPath}"
+	// DocsForgeTree redirects browsers to the package directory in the
+	// repository tree view of GitHub, GitLab, Gitea or sourcehut.
+	DocsForgeTree = "{repoURL}{tree}"
+)
+
+// DocsPkgsite returns a documentation URL template for a self-hosted pkgsite
+// instance at baseURL.
+func DocsPkgsite(baseURL string) string {
+	return addSuffixSlash(baseURL) + "{importPath}{@version}"
+}
+
+// DocsURL sets the URL template browsers are redirected to. The template may
+// contain the following placeholders:
+//
+//	{domain}      vanity server domain, such as kkn.fi
+//	{importPath}  import path of the request, such as kkn.fi/project/sub/pkg
+//	{moduleRoot}  import path of the module, such as kkn.fi/project
+//	{subPath}     package directory within the module, such as sub/pkg
+//	{repoURL}     repository URL, such as https://github.com/kare/project
+//	{repo}        repository URL without the scheme
+//	{repoName}    repository name, such as project
+//	{version}     requested version, such as v1.2.3, or empty
+//	{@version}    requested version prefixed with @, or empty
+//	{branch}      default branch of the repository, such as main
+//	{tree}        forge specific path of the package directory in the
+//	              repository tree view, such as /tree/main/sub/pkg, or empty
+//	              for the module root
+//
+// The forge of {tree} is deduced from the host of the URL preceding it. See
+// DefaultForge() and DefaultBranch().
+// Presets are available for pkg.go.dev, godocs.io, pkgsite, Sourcegraph and
+// the forge tree view. Individual modules may override the template.
+func DocsURL(template string) Option {
+	return func(h http.Handler) error {
+		v := h.(*handler)
+		v.config.DocsURL = template
+		return nil
+	}
+}
+
+// browserURL expands the documentation URL template of t.
+func (t *target) browserURL() string {
+	version := ""
+	if t.version != "" {
+		version = "@" + t.version
+	}
+	repoURL := strings.TrimSuffix(stripSuffixSlash(t.repoURL), ".git")
+	repo := repoURL
+	if i := strings.Index(repo, "://"); i >= 0 {
+		repo = repo[i+len("://"):]
+	}
+	scheme := t.scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	r := strings.NewReplacer(
+		"{scheme}", scheme,
+		"{domain}", t.domain,
+		"{importPath}", t.importPath,
+		"{moduleRoot}", t.moduleRoot,
+		"{subPath}", t.subPath,
+		"{repoURL}", repoURL,
+		"{repo}", repo,
+		"{repoName}", t.repoName,
+		"{version}", t.version,
+		"{@version}", version,
+		"{branch}", t.branch,
+	)
+	u := r.Replace(t.docsURL)
+	const tree = "{tree}"
+	if i := strings.Index(u, tree); i >= 0 {
+		forge := t.forgeFor(u[:i])
+		u = u[:i] + forge.treePath(t.branch, t.subPath) + u[i+len(tree):]
+	}
+	return stripSuffixSlash(u)
+}
@@ -0,0 +1,195 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestDocsURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		template string
+		modules  []vanity.Module
+		result   string
+	}{
+		{
+			name:     "pkg.go.dev",
+			path:     "/project/sub/pkg",
+			template: vanity.DocsPkgGoDev,
+			result:   "https://pkg.go.dev/kkn.fi/project/sub/pkg",
+		},
+		{
+			name:     "pkg.go.dev with version",
+			path:     "/project@v1.2.3/sub/pkg",
+			template: vanity.DocsPkgGoDev,
+			result:   "https://pkg.go.dev/kkn.fi/project/sub/pkg@v1.2.3",
+		},
+		{
+			name:     "godocs.io",
+			path:     "/vanity",
+			template: vanity.DocsGodocsIO,
+			result:   "https://godocs.io/kkn.fi/vanity",
+		},
+		{
+			name:     "self-hosted pkgsite",
+			path:     "/cmd/tcpproxy",
+			template: vanity.DocsPkgsite("https://pkgsite.kkn.fi"),
+			result:   "https://pkgsite.kkn.fi/kkn.fi/cmd/tcpproxy",
+		},
+		{
+			name:     "sourcegraph",
+			path:     "/project/sub/pkg",
+			template: vanity.DocsSourcegraph,
+			result:   "https://sourcegraph.com/github.com/kare/project/-/tree/sub/pkg",
+		},
+		{
+			name:     "forge tree view",
+			path:     "/project/sub/pkg",
+			template: vanity.DocsForgeTree,
+			result:   "https://github.com/kare/project/tree/main/sub/pkg",
+		},
+		{
+			name:     "all placeholders",
+			path:     "/cmd/project@v0.1.0/sub",
+			template: "https://docs.kkn.fi/{domain}|{importPath}|{moduleRoot}|{subPath}|{repoURL}|{repo}|{repoName}|{version}",
+			result:   "https://docs.kkn.fi/kkn.fi|kkn.fi/cmd/project/sub|kkn.fi/cmd/project|sub|https://github.com/kare/project|github.com/kare/project|project|v0.1.0",
+		},
+		{
+			name:     "module overrides template",
+			path:     "/private/sub",
+			template: vanity.DocsPkgGoDev,
+			modules: []vanity.Module{
+				{
+					Path:    "/private",
+					RepoURL: "https://git.kkn.fi/private.git",
+					DocsURL: vanity.DocsPkgsite("https://pkgsite.kkn.fi"),
+				},
+			},
+			result: "https://pkgsite.kkn.fi/kkn.fi/private/sub",
+		},
+		{
+			name:     "module with longest prefix wins",
+			path:     "/project/v2/sub",
+			template: vanity.DocsPkgGoDev,
+			modules: []vanity.Module{
+				{
+					Path:    "/project",
+					DocsURL: vanity.DocsGodocsIO,
+				},
+				{
+					Path:    "/project/v2/",
+					DocsURL: "{repoURL}/{subPath}",
+				},
+			},
+			result: "https://github.com/kare/project/sub",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv, err := vanity.NewHandlerWithOptions(
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.DocsURL(test.template),
+				vanity.Modules(test.modules...),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != http.StatusTemporaryRedirect {
+				t.Errorf("expected response status %v, but got %v", http.StatusTemporaryRedirect, res.StatusCode)
+			}
+			if location := res.Header.Get("Location"); location != test.result {
+				t.Errorf("expecting redirect to\n%v, but got\n%v", test.result, location)
+			}
+		})
+	}
+}
+
+func TestModulesGoTool(t *testing.T) {
+	tests := []struct {
+		path   string
+		result string
+	}{
+		{
+			path:   "/private?go-get=1",
+			result: "kkn.fi/private hg https://hg.kkn.fi/private",
+		},
+		{
+			path:   "/private/sub/pkg?go-get=1",
+			result: "kkn.fi/private hg https://hg.kkn.fi/private",
+		},
+		{
+			path:   "/privateer?go-get=1",
+			result: "kkn.fi/privateer git https://github.com/kare/privateer",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv, err := vanity.NewHandlerWithOptions(
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(vanity.Module{
+					Path:    "private",
+					VCS:     "hg",
+					RepoURL: "https://hg.kkn.fi/private",
+				}),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			body, _ := io.ReadAll(res.Body)
+			expected := `<meta name="go-import" content="` + test.result + `">`
+			if !strings.Contains(string(body), expected) {
+				t.Errorf("expecting\n%v be contained in\n%s", expected, body)
+			}
+		})
+	}
+}
+
+func TestModulesInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules []vanity.Module
+	}{
+		{
+			name:    "empty path",
+			modules: []vanity.Module{{Path: "/"}},
+		},
+		{
+			name:    "duplicate path",
+			modules: []vanity.Module{{Path: "/vanity"}, {Path: "vanity/"}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := vanity.NewHandlerWithOptions(vanity.Modules(test.modules...))
+			if err == nil {
+				t.Error("expecting error, but got nil")
+			}
+		})
+	}
+}
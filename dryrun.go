@@ -0,0 +1,167 @@
+package vanity
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Clients of dry-run requests.
+const (
+	// ClientGo is the go tool requesting an import path with ?go-get=1.
+	ClientGo = "go"
+	// ClientBrowser is a web browser requesting an import path.
+	ClientBrowser = "browser"
+)
+
+// Response is the response of a handler to a dry-run request.
+type Response struct {
+	// Client is ClientGo or ClientBrowser.
+	Client string
+	// URL is the request URL, such as https://kkn.fi/x?go-get=1.
+	URL    string
+	Status int
+	Header http.Header
+	Body   string
+}
+
+// String returns the status line, headers and body of r like in HTTP/1.1.
+func (r *Response) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %03d %v\r\n", r.Status, http.StatusText(r.Status))
+	_ = r.Header.Write(&buf)
+	buf.WriteString("\r\n")
+	buf.WriteString(r.Body)
+	return strings.ReplaceAll(buf.String(), "\r\n", "\n")
+}
+
+// dryRun responds to a request of client to rawURL with h.
+func dryRun(h http.Handler, client, rawURL string) (*Response, error) {
+	r, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vanity: malformed import path: %w", err)
+	}
+	switch client {
+	case ClientGo:
+		r.Header.Set("User-Agent", "Go-http-client/1.1")
+	case ClientBrowser:
+		r.Header.Set("User-Agent", "Mozilla/5.0")
+		r.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	}
+	w := &exportResponse{header: make(http.Header)}
+	h.ServeHTTP(w, r)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return &Response{
+		Client: client,
+		URL:    rawURL,
+		Status: w.status,
+		Header: w.header,
+		Body:   w.body.String(),
+	}, nil
+}
+
+// Resolve returns the responses of h to the go tool and to a browser
+// requesting importPath, such as kkn.fi/x/sub or kkn.fi/x@v1.2.0, without
+// serving them. The host of the import path is the host of the requests.
+func Resolve(h http.Handler, importPath string) ([]*Response, error) {
+	importPath = strings.TrimPrefix(strings.TrimPrefix(importPath, "https://"), "http://")
+	if importPath == "" || strings.HasPrefix(importPath, "/") {
+		return nil, fmt.Errorf("vanity: import path %q has no host", importPath)
+	}
+	if !strings.Contains(importPath, "/") {
+		importPath += "/"
+	}
+	sep := "?"
+	if strings.Contains(importPath, "?") {
+		sep = "&"
+	}
+	var responses []*Response
+	for _, req := range []struct {
+		client string
+		url    string
+	}{
+		{ClientGo, "https://" + importPath + sep + "go-get=1"},
+		{ClientBrowser, "https://" + importPath},
+	} {
+		res, err := dryRun(h, req.client, req.url)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, res)
+	}
+	return responses, nil
+}
+
+// ResponseDiff is a changed response to a request.
+type ResponseDiff struct {
+	// Client is ClientGo or ClientBrowser.
+	Client string
+	// URL is the request URL.
+	URL string
+	// Diff is the unified diff of the responses.
+	Diff string
+}
+
+// DiffHandlers compares the responses of handlers a and b to the go tool
+// and browsers over the known paths of both: the index page, the API, the
+// configured modules, their packages with local sources, feeds, robots.txt
+// and the sitemap. The changed responses are returned ordered by URL. The
+// handlers must be created with NewHandlerWithOptions, and either of them
+// must have a domain.
+func DiffHandlers(a, b http.Handler) ([]ResponseDiff, error) {
+	va, okA := a.(*handler)
+	vb, okB := b.(*handler)
+	if !okA || !okB {
+		return nil, errors.New("vanity: diff requires handlers created with NewHandlerWithOptions")
+	}
+	domain := va.domain
+	if domain == "" {
+		domain = vb.domain
+	}
+	if domain == "" {
+		return nil, errors.New("vanity: diff requires a domain")
+	}
+	known := make(map[string]bool)
+	for _, v := range []*handler{va, vb} {
+		paths, err := v.exportPaths()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range append(paths, apiModulesPath) {
+			known[p] = true
+		}
+	}
+	paths := make([]string, 0, len(known))
+	for p := range known {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var diffs []ResponseDiff
+	for _, p := range paths {
+		resA, err := Resolve(a, domain+p)
+		if err != nil {
+			return nil, err
+		}
+		resB, err := Resolve(b, domain+p)
+		if err != nil {
+			return nil, err
+		}
+		for i := range resA {
+			diff := unifiedDiff("a", "b", resA[i].String(), resB[i].String())
+			if diff == "" {
+				continue
+			}
+			diffs = append(diffs, ResponseDiff{
+				Client: resA[i].Client,
+				URL:    resA[i].URL,
+				Diff:   diff,
+			})
+		}
+	}
+	return diffs, nil
+}
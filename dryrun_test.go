@@ -0,0 +1,134 @@
+package vanity_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestResolve(t *testing.T) {
+	h, err := vanity.NewHandlerWithOptions(
+		vanity.Domain("kkn.fi"),
+		vanity.VCSURL("https://github.com/kare"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		importPath string
+		client     string
+		url        string
+		status     int
+		contains   string
+	}{
+		{"go tool", "kkn.fi/x/sub", vanity.ClientGo, "https://kkn.fi/x/sub?go-get=1", http.StatusOK, `<meta name="go-import" content="kkn.fi/x/sub git https://github.com/kare/x">`},
+		{"browser", "kkn.fi/x/sub", vanity.ClientBrowser, "https://kkn.fi/x/sub", http.StatusTemporaryRedirect, "Location: https://pkg.go.dev/kkn.fi/x/sub\n"},
+		{"URL", "https://kkn.fi/x", vanity.ClientGo, "https://kkn.fi/x?go-get=1", http.StatusOK, "HTTP/1.1 200 OK\nContent-Type: text/html; charset=utf-8\n\n<!doctype html>"},
+		{"index", "kkn.fi", vanity.ClientBrowser, "https://kkn.fi/", http.StatusOK, "<!doctype html>"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			responses, err := vanity.Resolve(h, test.importPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(responses) != 2 {
+				t.Fatalf("expected 2 responses, but got %v", len(responses))
+			}
+			for _, res := range responses {
+				if res.Client != test.client {
+					continue
+				}
+				if res.URL != test.url {
+					t.Errorf("expected URL %v, but got %v", test.url, res.URL)
+				}
+				if res.Status != test.status {
+					t.Errorf("expected status %v, but got %v", test.status, res.Status)
+				}
+				if s := res.String(); !strings.Contains(s, test.contains) {
+					t.Errorf("expected response to contain %q, but got %q", test.contains, s)
+				}
+				return
+			}
+			t.Errorf("no response to client %v", test.client)
+		})
+	}
+}
+
+func TestResolveNoHost(t *testing.T) {
+	h, err := vanity.NewHandlerWithOptions(vanity.VCSURL("https://github.com/kare"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vanity.Resolve(h, "/x"); err == nil {
+		t.Fatal("expected import path without host to fail")
+	}
+}
+
+func TestDiffHandlers(t *testing.T) {
+	a, err := vanity.NewHandlerWithOptions(
+		vanity.Domain("kkn.fi"),
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(vanity.Module{Path: "/x"}, vanity.Module{Path: "/y"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := vanity.NewHandlerWithOptions(
+		vanity.Domain("kkn.fi"),
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(vanity.Module{Path: "/x", RepoURL: "https://gitlab.com/kare/x"}, vanity.Module{Path: "/y"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := vanity.DiffHandlers(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected no differences of the same handler, but got %+v", diffs)
+	}
+
+	diffs, err = vanity.DiffHandlers(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := make(map[string]string)
+	for _, d := range diffs {
+		changed[d.Client+" "+d.URL] = d.Diff
+	}
+	goImport := changed[vanity.ClientGo+" https://kkn.fi/x?go-get=1"]
+	if !strings.Contains(goImport, `-    <meta name="go-import" content="kkn.fi/x git https://github.com/kare/x">`) ||
+		!strings.Contains(goImport, `+    <meta name="go-import" content="kkn.fi/x git https://gitlab.com/kare/x">`) {
+		t.Errorf("expected go-import of /x to change, but got %q", goImport)
+	}
+	if _, ok := changed[vanity.ClientBrowser+" https://kkn.fi/api/modules"]; !ok {
+		t.Errorf("expected API response to change, but got %v", changed)
+	}
+	for _, unchanged := range []string{
+		vanity.ClientGo + " https://kkn.fi/y?go-get=1",
+		vanity.ClientBrowser + " https://kkn.fi/y",
+		vanity.ClientBrowser + " https://kkn.fi/x",
+	} {
+		if diff, ok := changed[unchanged]; ok {
+			t.Errorf("expected %v not to change, but got %q", unchanged, diff)
+		}
+	}
+}
+
+func TestDiffHandlersNoDomain(t *testing.T) {
+	h, err := vanity.NewHandlerWithOptions(vanity.VCSURL("https://github.com/kare"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vanity.DiffHandlers(h, h); err == nil {
+		t.Fatal("expected diff without domain to fail")
+	}
+}
@@ -0,0 +1,143 @@
+package vanity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OptionsFromEnv returns the options set by environment variables. Variable
+// names are prefix followed by an underscore and the name below, such as
+// VANITY_DOMAIN for prefix VANITY:
+//
+//	DOMAIN             Domain()
+//	VCS                VCS()
+//	VCS_URL            VCSURL()
+//	MODULE_SERVER_URL  ModuleServerURL()
+//	DOCS_URL           DocsURL()
+//	FORGE              DefaultForge()
+//	BRANCH             DefaultBranch()
+//	STATIC_DIR         StaticDir() served at STATIC_URL, default /.static/
+//	ROBOTS_TXT         RobotsTxt() with the contents of robots.txt
+//	ROBOTS_TXT_FILE    RobotsTxt() with the contents of the named file
+//	LANDING            LandingPage() with the default template if true
+//	LOCAL_DOCS         LocalDocs() with the default template if true
+//	CACHE_MAX_AGE      CacheMaxAge() as a duration, such as 1h, or seconds
+//	MODULES            Modules() as a JSON array of modules
+//
+// Modules are given in the JSON format of configuration files:
+//
+//	VANITY_MODULES='[{"path": "/vanity"}, {"path": "/x", "repoURL": "https://gitlab.com/kare/x"}]'
+//
+// Unset and empty variables are ignored. Malformed values are errors naming
+// the variable.
+func OptionsFromEnv(prefix string) ([]Option, error) {
+	varName := func(name string) string {
+		if prefix != "" {
+			return prefix + "_" + name
+		}
+		return name
+	}
+	env := func(name string) (string, string) {
+		name = varName(name)
+		return name, strings.TrimSpace(os.Getenv(name))
+	}
+	var opts []Option
+	var errs []error
+	str := func(name string, option func(string) Option) {
+		if _, v := env(name); v != "" {
+			opts = append(opts, option(v))
+		}
+	}
+	boolean := func(name string, option Option) {
+		name, v := env(name)
+		if v == "" {
+			return
+		}
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vanity: malformed %v %q: expected a boolean", name, v))
+			return
+		}
+		if enabled {
+			opts = append(opts, option)
+		}
+	}
+	str("DOMAIN", Domain)
+	str("VCS", VCS)
+	str("VCS_URL", VCSURL)
+	str("MODULE_SERVER_URL", ModuleServerURL)
+	str("DOCS_URL", DocsURL)
+	str("FORGE", func(v string) Option { return DefaultForge(Forge(v)) })
+	str("BRANCH", DefaultBranch)
+	if _, dir := env("STATIC_DIR"); dir != "" {
+		_, urlPath := env("STATIC_URL")
+		if urlPath == "" {
+			urlPath = defaultStaticURL
+		}
+		opts = append(opts, StaticDir(dir, urlPath))
+	}
+	// robots.txt is read untrimmed to keep its final newline.
+	robotsTxt := os.Getenv(varName("ROBOTS_TXT"))
+	if strings.TrimSpace(robotsTxt) == "" {
+		robotsTxt = ""
+	}
+	if name, file := env("ROBOTS_TXT_FILE"); file != "" {
+		if robotsTxt != "" {
+			errs = append(errs, fmt.Errorf("vanity: both %v and %v are set", varName("ROBOTS_TXT"), name))
+		} else if b, err := os.ReadFile(file); err != nil {
+			errs = append(errs, fmt.Errorf("vanity: error reading %v: %w", name, err))
+		} else {
+			robotsTxt = string(b)
+		}
+	}
+	if robotsTxt != "" {
+		opts = append(opts, RobotsTxt(robotsTxt))
+	}
+	boolean("LANDING", LandingPage(nil))
+	boolean("LOCAL_DOCS", LocalDocs(nil))
+	if name, v := env("CACHE_MAX_AGE"); v != "" {
+		maxAge, err := parseMaxAge(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vanity: malformed %v %q: %w", name, v, err))
+		} else {
+			opts = append(opts, CacheMaxAge(maxAge))
+		}
+	}
+	if name, v := env("MODULES"); v != "" {
+		var modules []Module
+		dec := json.NewDecoder(strings.NewReader(v))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&modules); err != nil {
+			errs = append(errs, fmt.Errorf("vanity: malformed %v: expected a JSON array of modules: %w", name, err))
+		} else {
+			opts = append(opts, Modules(modules...))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// parseMaxAge parses a duration, such as 1h, or a number of seconds.
+func parseMaxAge(s string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(s); err == nil {
+		if seconds < 0 {
+			return 0, errors.New("negative max age")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.New("expected a duration, such as 1h, or seconds")
+	}
+	if d < 0 {
+		return 0, errors.New("negative max age")
+	}
+	return d, nil
+}
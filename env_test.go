@@ -0,0 +1,176 @@
+package vanity_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("TEST_DOMAIN", "example.com")
+	t.Setenv("TEST_VCS_URL", "https://github.com/kare")
+	t.Setenv("TEST_MODULE_SERVER_URL", "https://godocs.io")
+	t.Setenv("TEST_ROBOTS_TXT", "robots are here\n")
+	t.Setenv("TEST_CACHE_MAX_AGE", "1h")
+	t.Setenv("TEST_MODULES", `[{"path": "/x", "repoURL": "https://gitlab.com/kare/x", "vcs": "hg"}]`)
+	opts, err := vanity.OptionsFromEnv("TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := vanity.NewHandlerWithOptions(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		contains string
+		location string
+	}{
+		{
+			name:     "module",
+			path:     "/x?go-get=1",
+			contains: `<meta name="go-import" content="example.com/x hg https://gitlab.com/kare/x">`,
+		},
+		{
+			name:     "vcs url",
+			path:     "/vanity?go-get=1",
+			contains: `<meta name="go-import" content="example.com/vanity git https://github.com/kare/vanity">`,
+		},
+		{
+			name:     "module server url",
+			path:     "/vanity",
+			location: "https://godocs.io/example.com/vanity",
+		},
+		{
+			name:     "robots.txt",
+			path:     "/robots.txt",
+			contains: "robots are here\n",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			body, _ := io.ReadAll(res.Body)
+			if !strings.Contains(string(body), test.contains) {
+				t.Errorf("expected body to contain %q, but got:\n%s", test.contains, body)
+			}
+			if test.location != "" && res.Header.Get("Location") != test.location {
+				t.Errorf("expected location %v, but got %v", test.location, res.Header.Get("Location"))
+			}
+			if cc := res.Header.Get("Cache-Control"); cc != "public, max-age=3600" {
+				t.Errorf("expected Cache-Control max-age of an hour, but got %q", cc)
+			}
+		})
+	}
+}
+
+func TestOptionsFromEnvRobotsTxtFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "robots.txt")
+	if err := os.WriteFile(name, []byte("robots are in a file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_ROBOTS_TXT_FILE", name)
+	opts, err := vanity.OptionsFromEnv("TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := vanity.NewHandlerWithOptions(append(opts, vanity.VCSURL("https://github.com/kare"))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, addr+"/robots.txt", nil))
+	body, _ := io.ReadAll(rec.Result().Body)
+	if string(body) != "robots are in a file\n" {
+		t.Errorf("expected robots.txt of the file, but got %q", body)
+	}
+}
+
+func TestOptionsFromEnvInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		err   string
+		build bool
+	}{
+		{
+			name: "malformed modules",
+			env:  map[string]string{"TEST_MODULES": `{"path": "/x"}`},
+			err:  "vanity: malformed TEST_MODULES: expected a JSON array of modules",
+		},
+		{
+			name: "unknown module field",
+			env:  map[string]string{"TEST_MODULES": `[{"path": "/x", "repo": "https://gitlab.com/kare/x"}]`},
+			err:  `json: unknown field "repo"`,
+		},
+		{
+			name: "malformed boolean",
+			env:  map[string]string{"TEST_LANDING": "yes please"},
+			err:  `vanity: malformed TEST_LANDING "yes please": expected a boolean`,
+		},
+		{
+			name: "malformed cache max age",
+			env:  map[string]string{"TEST_CACHE_MAX_AGE": "a day"},
+			err:  `vanity: malformed TEST_CACHE_MAX_AGE "a day": expected a duration, such as 1h, or seconds`,
+		},
+		{
+			name: "negative cache max age",
+			env:  map[string]string{"TEST_CACHE_MAX_AGE": "-60"},
+			err:  `vanity: malformed TEST_CACHE_MAX_AGE "-60": negative max age`,
+		},
+		{
+			name: "missing robots.txt file",
+			env:  map[string]string{"TEST_ROBOTS_TXT_FILE": "testdata/missing.txt"},
+			err:  "vanity: error reading TEST_ROBOTS_TXT_FILE: open testdata/missing.txt: no such file or directory",
+		},
+		{
+			name: "robots.txt and robots.txt file",
+			env: map[string]string{
+				"TEST_ROBOTS_TXT":      "User-agent: *\n",
+				"TEST_ROBOTS_TXT_FILE": "testdata/robots.txt",
+			},
+			err: "vanity: both TEST_ROBOTS_TXT and TEST_ROBOTS_TXT_FILE are set",
+		},
+		{
+			name:  "invalid module",
+			env:   map[string]string{"TEST_MODULES": `[{"path": ""}]`},
+			err:   "vanity: module path is empty",
+			build: true,
+		},
+		{
+			name:  "unknown forge",
+			env:   map[string]string{"TEST_FORGE": "bitbucket"},
+			err:   `vanity: unknown forge "bitbucket"`,
+			build: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			opts, err := vanity.OptionsFromEnv("TEST")
+			if test.build {
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = vanity.NewHandlerWithOptions(opts...)
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing %q, but got %v", test.err, err)
+			}
+		})
+	}
+}
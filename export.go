@@ -0,0 +1,164 @@
+package vanity
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportResponse is an in-memory http.ResponseWriter of an exported page.
+type exportResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *exportResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *exportResponse) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+func (r *exportResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// get responds to a GET request of rawPath as ServeHTTP does.
+func (h *handler) get(rawPath string) (*exportResponse, error) {
+	r, err := http.NewRequest(http.MethodGet, "https://"+h.domain+rawPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vanity: error exporting %v: %w", rawPath, err)
+	}
+	w := &exportResponse{header: make(http.Header)}
+	h.ServeHTTP(w, r)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w, nil
+}
+
+// exportPaths returns the request paths of the pages of the configured
+// modules and their packages with local sources.
+func (h *handler) exportPaths() ([]string, error) {
+	paths := []string{"/"}
+	for _, m := range h.modules {
+		paths = append(paths, m.Path)
+		if m.SourceDir != "" {
+			pkgs, err := subPackages(m.SourceDir, m.Path, "")
+			if err != nil {
+				return nil, fmt.Errorf("vanity: error listing packages of %v: %w", m.Path, err)
+			}
+			for _, pkg := range pkgs {
+				paths = append(paths, pkg.Path)
+			}
+		}
+		if m.RepoDir != "" {
+			paths = append(paths, m.Path+feedPath)
+		}
+	}
+	for _, m := range h.modules {
+		if m.RepoDir != "" {
+			paths = append(paths, feedPath)
+			break
+		}
+	}
+	return append(paths, robotsTxtPath, sitemapPath), nil
+}
+
+// Export writes a static site of handler h to directory dir. The site
+// contains an index.html file per import path of the configured modules, the
+// index page, feeds, robots.txt, the sitemap and the static directory. Pages
+// are rendered by ServeHTTP. Paths that ServeHTTP redirects to documentation
+// are exported as the go tool response, which refreshes to the
+// documentation. Import paths without a configured module can't be
+// enumerated and aren't exported. Domain() must be set. The handler must be
+// created with NewHandlerWithOptions.
+func Export(h http.Handler, dir string) error {
+	v, ok := h.(*handler)
+	if !ok {
+		return errors.New("vanity: export requires a handler created with NewHandlerWithOptions")
+	}
+	if v.domain == "" {
+		return errors.New("vanity: export requires a domain")
+	}
+	paths, err := v.exportPaths()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		res, err := v.get(p)
+		if err != nil {
+			return err
+		}
+		if res.status >= 300 && res.status < 400 {
+			res, err = v.get(p + "?go-get=1")
+			if err != nil {
+				return err
+			}
+		}
+		if res.status != http.StatusOK {
+			return fmt.Errorf("vanity: error exporting %v: %v", p, http.StatusText(res.status))
+		}
+		name := filepath.FromSlash(strings.TrimPrefix(p, "/"))
+		if !isExportFile(p) {
+			name = filepath.Join(name, "index.html")
+		}
+		if err := writeExportFile(filepath.Join(dir, name), res.body.Bytes()); err != nil {
+			return err
+		}
+	}
+	if v.static != nil {
+		return copyDir(filepath.Join(dir, filepath.FromSlash(strings.Trim(v.static.uRLPath, "/"))), v.static.path)
+	}
+	return nil
+}
+
+// isExportFile reports whether the page at p is exported as a file instead of
+// an index.html file of a directory.
+func isExportFile(p string) bool {
+	return p == robotsTxtPath || p == sitemapPath || strings.HasSuffix(p, feedPath)
+}
+
+// writeExportFile writes a file of an exported site, creating its directory.
+func writeExportFile(name string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("vanity: error creating export directory: %w", err)
+	}
+	if err := os.WriteFile(name, b, 0o644); err != nil {
+		return fmt.Errorf("vanity: error writing exported file: %w", err)
+	}
+	return nil
+}
+
+// copyDir copies the regular files of directory src to directory dst.
+func copyDir(dst, src string) error {
+	return filepath.WalkDir(src, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("vanity: error exporting static dir: %w", err)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return fmt.Errorf("vanity: error exporting static dir: %w", err)
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("vanity: error exporting static dir: %w", err)
+		}
+		return writeExportFile(filepath.Join(dst, rel), b)
+	})
+}
@@ -0,0 +1,143 @@
+package vanity_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestExportIntegration(t *testing.T) {
+	integrationTest(t)
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.Domain("kkn.fi"),
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.LocalDocs(nil),
+		vanity.StaticDir("testdata", "/.static/"),
+		vanity.Modules(
+			vanity.Module{Path: "/greet", SourceDir: "testdata/docs/greet"},
+			vanity.Module{Path: "/project"},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := vanity.Export(srv, dir); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		file     string
+		contains []string
+	}{
+		{
+			name: "index",
+			file: "index.html",
+			contains: []string{
+				`<p>homepage</p>`,
+			},
+		},
+		{
+			name: "redirected module",
+			file: "project/index.html",
+			contains: []string{
+				`<meta name="go-import" content="kkn.fi/project git https://github.com/kare/project">`,
+				`<meta name="go-source" content="kkn.fi/project https://github.com/kare/project https://github.com/kare/project/tree/main{/dir} https://github.com/kare/project/blob/main{/dir}/{file}#L{line}">`,
+				`<meta http-equiv="refresh" content="0; url=https://pkg.go.dev/kkn.fi/project">`,
+			},
+		},
+		{
+			name: "documentation",
+			file: "greet/index.html",
+			contains: []string{
+				`<meta name="go-import" content="kkn.fi/greet git https://github.com/kare/greet">`,
+				`<h1>package greet</h1>`,
+			},
+		},
+		{
+			name: "sub-package documentation",
+			file: "greet/sub/inner/index.html",
+			contains: []string{
+				`<meta name="go-import" content="kkn.fi/greet git https://github.com/kare/greet">`,
+				`<h1>package inner</h1>`,
+			},
+		},
+		{
+			name: "robots.txt",
+			file: "robots.txt",
+			contains: []string{
+				`Sitemap: https://kkn.fi/sitemap.xml`,
+			},
+		},
+		{
+			name: "sitemap",
+			file: "sitemap.xml",
+			contains: []string{
+				`<loc>https://kkn.fi/greet/sub</loc>`,
+			},
+		},
+		{
+			name: "static file",
+			file: ".static/docs/greet/sub/sub.go",
+			contains: []string{
+				`package sub`,
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := os.ReadFile(filepath.Join(dir, test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range test.contains {
+				if !strings.Contains(string(b), s) {
+					t.Errorf("expected %v to contain %q, but got:\n%s", test.file, s, b)
+				}
+			}
+		})
+	}
+}
+
+func TestExportInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		err     string
+	}{
+		{
+			name:    "foreign handler",
+			handler: http.NotFoundHandler(),
+			err:     "vanity: export requires a handler created with NewHandlerWithOptions",
+		},
+		{
+			name: "without domain",
+			handler: func() http.Handler {
+				srv, err := vanity.NewHandlerWithOptions(vanity.VCSURL("https://github.com/kare"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return srv
+			}(),
+			err: "vanity: export requires a domain",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := vanity.Export(test.handler, t.TempDir())
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, but got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package vanity
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// feedPath is the path of the release feed of all modules and the path
+// suffix of the release feed of a module.
+const feedPath = "/feed.atom"
+
+// feedMaxEntries is the maximum number of releases in a feed.
+const feedMaxEntries = 50
+
+// atomFeed is an Atom feed as specified in RFC 4287.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Entry   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Link    []atomLink `xml:"link"`
+	Content *atomText  `xml:"content,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// release is a version of a module.
+type release struct {
+	target  *target
+	version Version
+}
+
+// releases returns the versions of the given modules from the newest to the
+// oldest. Modules whose versions can't be read are left out, and their
+// errors are returned joined.
+func (h *handler) releases(domain string, modules []Module) ([]release, error) {
+	var releases []release
+	var errs []error
+	for _, m := range modules {
+		if m.RepoDir == "" {
+			continue
+		}
+		t := h.resolve(domain, m.Path)
+		versions, err := h.versions(t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, v := range versions {
+			releases = append(releases, release{target: t, version: v})
+		}
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].version.Time.After(releases[j].version.Time)
+	})
+	if len(releases) > feedMaxEntries {
+		releases = releases[:feedMaxEntries]
+	}
+	return releases, errors.Join(errs...)
+}
+
+// serveFeed responds with an Atom feed of the releases of the module of t, or
+// of all public modules if t is nil.
+func (h *handler) serveFeed(w http.ResponseWriter, r *http.Request, domain string, t *target) {
+	modules := h.publicModules()
+	feedURL := "https://" + domain + feedPath
+	title := domain + " releases"
+	if t != nil {
+		if t.module == nil || t.module.RepoDir == "" || t.subPath != "" {
+			http.NotFound(w, r)
+			return
+		}
+		modules = []Module{*t.module}
+		feedURL = "https://" + t.moduleRoot + feedPath
+		title = t.moduleRoot + " releases"
+	}
+	releases, err := h.releases(domain, modules)
+	if err != nil {
+		h.log.Printf("%v", err)
+		// The feed of all modules is served without the modules whose
+		// versions can't be read.
+		if t != nil {
+			status := http.StatusInternalServerError
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+	feed := atomFeed{
+		ID:    feedURL,
+		Title: title,
+		Link: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: feedURL},
+		},
+		Author: &atomPerson{Name: domain},
+	}
+	// Feed's updated time is the newest release or the Unix epoch when there
+	// are no releases, so that the feed is stable between requests.
+	updated := time.Unix(0, 0)
+	for _, rel := range releases {
+		if rel.version.Time.After(updated) {
+			updated = rel.version.Time
+		}
+		rt := *rel.target
+		rt.version = rel.version.Version
+		entry := atomEntry{
+			ID:      "https://" + rt.moduleRoot + "@" + rt.version,
+			Title:   rt.moduleRoot + " " + rt.version,
+			Updated: rel.version.Time.UTC().Format(time.RFC3339),
+			Link: []atomLink{
+				{Rel: "alternate", Type: "text/html", Href: rt.browserURL()},
+			},
+		}
+		if msg := strings.TrimSpace(rel.version.Message); msg != "" {
+			entry.Content = &atomText{Type: "text", Body: msg}
+		}
+		feed.Entry = append(feed.Entry, entry)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		h.log.Printf("vanity: i/o error writing feed response: %v", err)
+		return
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		h.log.Printf("vanity: i/o error writing feed response: %v", err)
+	}
+}
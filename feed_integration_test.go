@@ -0,0 +1,237 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestFeedIntegration(t *testing.T) {
+	integrationTest(t)
+	project := gitRepo(t, nil,
+		testTag{name: "v1.0.0", date: "2021-01-01T00:00:00Z", message: "First release.\n\nFixes <everything>."},
+		testTag{name: "v1.1.0", date: "2021-03-01T00:00:00Z"},
+	)
+	tool := gitRepo(t, nil,
+		testTag{name: "v0.1.0", date: "2021-02-01T00:00:00Z", message: "Tool release."},
+	)
+	modules := []vanity.Module{
+		{Path: "/project", RepoDir: project},
+		{Path: "/cmd/tool", RepoDir: tool, DocsURL: vanity.DocsForgeTree},
+		{Path: "/gist"},
+	}
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "all modules",
+			path:   "/feed.atom",
+			status: http.StatusOK,
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>https://kkn.fi/feed.atom</id>
+  <title>kkn.fi releases</title>
+  <updated>2021-03-01T00:00:00Z</updated>
+  <link rel="self" type="application/atom+xml" href="https://kkn.fi/feed.atom"></link>
+  <author>
+    <name>kkn.fi</name>
+  </author>
+  <entry>
+    <id>https://kkn.fi/project@v1.1.0</id>
+    <title>kkn.fi/project v1.1.0</title>
+    <updated>2021-03-01T00:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://pkg.go.dev/kkn.fi/project@v1.1.0"></link>
+    <content type="text">release v1.1.0</content>
+  </entry>
+  <entry>
+    <id>https://kkn.fi/cmd/tool@v0.1.0</id>
+    <title>kkn.fi/cmd/tool v0.1.0</title>
+    <updated>2021-02-01T00:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://github.com/kare/tool"></link>
+    <content type="text">Tool release.</content>
+  </entry>
+  <entry>
+    <id>https://kkn.fi/project@v1.0.0</id>
+    <title>kkn.fi/project v1.0.0</title>
+    <updated>2021-01-01T00:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://pkg.go.dev/kkn.fi/project@v1.0.0"></link>
+    <content type="text">First release.&#xA;&#xA;Fixes &lt;everything&gt;.</content>
+  </entry>
+</feed>`,
+		},
+		{
+			name:   "module",
+			path:   "/cmd/tool/feed.atom",
+			status: http.StatusOK,
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>https://kkn.fi/cmd/tool/feed.atom</id>
+  <title>kkn.fi/cmd/tool releases</title>
+  <updated>2021-02-01T00:00:00Z</updated>
+  <link rel="self" type="application/atom+xml" href="https://kkn.fi/cmd/tool/feed.atom"></link>
+  <author>
+    <name>kkn.fi</name>
+  </author>
+  <entry>
+    <id>https://kkn.fi/cmd/tool@v0.1.0</id>
+    <title>kkn.fi/cmd/tool v0.1.0</title>
+    <updated>2021-02-01T00:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://github.com/kare/tool"></link>
+    <content type="text">Tool release.</content>
+  </entry>
+</feed>`,
+		},
+		{
+			name:   "module without repository",
+			path:   "/gist/feed.atom",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "package of module",
+			path:   "/project/sub/feed.atom",
+			status: http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv, err := vanity.NewHandlerWithOptions(
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(modules...),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != test.status {
+				t.Errorf("expected response status %v, but got %v", test.status, res.StatusCode)
+			}
+			if test.body == "" {
+				return
+			}
+			if ct := res.Header.Get("Content-Type"); ct != "application/atom+xml; charset=utf-8" {
+				t.Errorf("unexpected content type %v", ct)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if string(body) != test.body {
+				t.Errorf("expecting body\n%v, but got\n%s", test.body, body)
+			}
+		})
+	}
+}
+
+func TestFeedPrivateModuleIntegration(t *testing.T) {
+	integrationTest(t)
+	project := gitRepo(t, nil, testTag{name: "v1.0.0", date: "2021-01-01T00:00:00Z"})
+	secret := gitRepo(t, nil, testTag{name: "v1.2.0", date: "2021-02-01T00:00:00Z"})
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(
+			vanity.Module{Path: "/project", RepoDir: project},
+			vanity.Module{Path: "/secret", RepoDir: secret, Private: true},
+		),
+		vanity.Log(log.New(io.Discard, "", 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		private bool
+	}{
+		{
+			name: "all modules",
+			path: "/feed.atom",
+		},
+		{
+			name:    "private module",
+			path:    "/secret/feed.atom",
+			private: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("expected response status 200, but got %v", res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if got := strings.Contains(string(body), "kkn.fi/secret v1.2.0"); got != test.private {
+				t.Errorf("expected private release listed %v, but got:\n%s", test.private, body)
+			}
+		})
+	}
+}
+
+func TestFeedBrokenRepositoryIntegration(t *testing.T) {
+	integrationTest(t)
+	project := gitRepo(t, nil, testTag{name: "v1.0.0", date: "2021-01-01T00:00:00Z"})
+	broken := t.TempDir()
+	var logs strings.Builder
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(
+			vanity.Module{Path: "/broken", RepoDir: broken},
+			vanity.Module{Path: "/project", RepoDir: project},
+		),
+		vanity.Log(log.New(&logs, "", 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		contains string
+	}{
+		{
+			name:     "all modules",
+			path:     "/feed.atom",
+			status:   http.StatusOK,
+			contains: "<title>kkn.fi/project v1.0.0</title>",
+		},
+		{
+			name:   "broken module",
+			path:   "/broken/feed.atom",
+			status: http.StatusInternalServerError,
+		},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+		srv.ServeHTTP(rec, req)
+		res := rec.Result()
+		if res.StatusCode != test.status {
+			t.Errorf("%v: expected response status %v, but got %v", test.name, test.status, res.StatusCode)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), test.contains) {
+			t.Errorf("%v: expected body to contain %q, but got:\n%s", test.name, test.contains, body)
+		}
+	}
+	if !strings.Contains(logs.String(), broken) {
+		t.Errorf("expected the error of the broken repository to be logged, but got %q", logs.String())
+	}
+}
@@ -0,0 +1,36 @@
+package vanity_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestFeedWithoutModules(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, addr+"/feed.atom", nil)
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Result().Body)
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>https://kkn.fi/feed.atom</id>
+  <title>kkn.fi releases</title>
+  <updated>1970-01-01T00:00:00Z</updated>
+  <link rel="self" type="application/atom+xml" href="https://kkn.fi/feed.atom"></link>
+  <author>
+    <name>kkn.fi</name>
+  </author>
+</feed>`
+	if string(body) != expected {
+		t.Errorf("expecting body\n%v, but got\n%s", expected, body)
+	}
+}
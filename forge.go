@@ -0,0 +1,147 @@
+package vanity
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Forge is a source code hosting service. Forges differ in the URL layout of
+// their repository tree views.
+type Forge string
+
+// Supported forges.
+const (
+	// ForgeGitHub tree view is https://github.com/owner/repo/tree/main/sub.
+	ForgeGitHub Forge = "github"
+	// ForgeGitLab tree view is https://gitlab.com/owner/repo/-/tree/main/sub.
+	ForgeGitLab Forge = "gitlab"
+	// ForgeGitea tree view is https://codeberg.org/owner/repo/src/branch/main/sub.
+	// Forgejo uses the same layout.
+	ForgeGitea Forge = "gitea"
+	// ForgeSourcehut tree view is https://git.sr.ht/~owner/repo/tree/main/item/sub.
+	ForgeSourcehut Forge = "sourcehut"
+)
+
+// defaultBranch is the branch used in tree view URLs unless configured.
+const defaultBranch = "main"
+
+// forgeHosts maps well-known forge hosts to their forge.
+var forgeHosts = map[string]Forge{
+	"github.com":   ForgeGitHub,
+	"gitlab.com":   ForgeGitLab,
+	"codeberg.org": ForgeGitea,
+	"gitea.com":    ForgeGitea,
+	"git.sr.ht":    ForgeSourcehut,
+	"hg.sr.ht":     ForgeSourcehut,
+}
+
+func (f Forge) valid() bool {
+	switch f {
+	case ForgeGitHub, ForgeGitLab, ForgeGitea, ForgeSourcehut:
+		return true
+	}
+	return false
+}
+
+// treePath returns the path of directory sub on branch in the repository
+// tree view of f. Repository root has an empty tree path.
+func (f Forge) treePath(branch, sub string) string {
+	if sub == "" {
+		return ""
+	}
+	switch f {
+	case ForgeGitLab:
+		return "/-/tree/" + branch + "/" + sub
+	case ForgeGitea:
+		return "/src/branch/" + branch + "/" + sub
+	case ForgeSourcehut:
+		return "/tree/" + branch + "/item/" + sub
+	default:
+		return "/tree/" + branch + "/" + sub
+	}
+}
+
+// sourcePaths returns the paths of a directory and of a file on branch in
+// the repository tree view of f for the go-source meta tag. The paths contain
+// the {/dir}, {file} and {line} placeholders of go-source and are rooted at
+// directory subdir of the repository.
+func (f Forge) sourcePaths(branch, subdir string) (dir, file string) {
+	sub := ""
+	if subdir != "" {
+		sub = "/" + subdir
+	}
+	var tree, blob string
+	switch f {
+	case ForgeGitLab:
+		tree, blob = "/-/tree/"+branch+sub, "/-/blob/"+branch+sub
+	case ForgeGitea:
+		tree = "/src/branch/" + branch + sub
+		blob = tree
+	case ForgeSourcehut:
+		tree = "/tree/" + branch + "/item" + sub
+		blob = tree
+	default:
+		tree, blob = "/tree/"+branch+sub, "/blob/"+branch+sub
+	}
+	return tree + "{/dir}", blob + "{/dir}/{file}#L{line}"
+}
+
+// goSource returns the content of the go-source meta tag of t: the import
+// prefix followed by the home, directory and file URLs of the repository.
+// An empty string is returned for targets without a repository URL.
+// Modules may override the URLs.
+func (t *target) goSource() string {
+	if t.module != nil && t.module.GoSource != "" {
+		return t.importPrefix() + " " + t.module.GoSource
+	}
+	if t.repoURL == "" {
+		return ""
+	}
+	home := strings.TrimSuffix(stripSuffixSlash(t.repoURL), ".git")
+	// Paths without a configured module advertise the whole path as the
+	// import prefix, which is directory subPath of the repository. The
+	// prefix of modules is the repository root.
+	subdir := ""
+	if t.module == nil {
+		subdir = t.subPath
+	}
+	dir, file := t.forgeFor(home).sourcePaths(t.branch, subdir)
+	return t.importPrefix() + " " + home + " " + home + dir + " " + home + file
+}
+
+// forgeFor returns the forge serving rawURL. A forge configured for the
+// module takes precedence over well-known hosts, which take precedence over
+// the handler's default forge.
+func (t *target) forgeFor(rawURL string) Forge {
+	if t.module != nil && t.module.Forge != "" {
+		return t.module.Forge
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		if f, ok := forgeHosts[u.Hostname()]; ok {
+			return f
+		}
+	}
+	return t.forge
+}
+
+// DefaultForge sets the forge used for tree view URLs of repositories that
+// aren't hosted on a well-known forge host, such as a self-hosted GitLab.
+// DefaultForge defaults to GitHub.
+func DefaultForge(forge Forge) Option {
+	return func(h http.Handler) error {
+		v := h.(*handler)
+		v.config.Forge = forge
+		return nil
+	}
+}
+
+// DefaultBranch sets the branch used in tree view URLs. DefaultBranch
+// defaults to main and can be overridden per module.
+func DefaultBranch(branch string) Option {
+	return func(h http.Handler) error {
+		v := h.(*handler)
+		v.config.Branch = branch
+		return nil
+	}
+}
@@ -0,0 +1,149 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestForgeTreeView(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		options []vanity.Option
+		result  string
+	}{
+		{
+			name: "module server github redirects to sub package directory",
+			path: "/project/sub/package",
+			options: []vanity.Option{
+				vanity.ModuleServerURL("https://github.com/kare/"),
+			},
+			result: "https://github.com/kare/project/tree/main/sub/package",
+		},
+		{
+			name: "module server github redirects module root to repository",
+			path: "/cmd/project",
+			options: []vanity.Option{
+				vanity.ModuleServerURL("https://github.com/kare/"),
+			},
+			result: "https://github.com/kare/project",
+		},
+		{
+			name: "github",
+			path: "/project/sub/package",
+			options: []vanity.Option{
+				vanity.VCSURL("https://github.com/kare"),
+			},
+			result: "https://github.com/kare/project/tree/main/sub/package",
+		},
+		{
+			name: "gitlab",
+			path: "/project/sub/package",
+			options: []vanity.Option{
+				vanity.VCSURL("https://gitlab.com/kare"),
+			},
+			result: "https://gitlab.com/kare/project/-/tree/main/sub/package",
+		},
+		{
+			name: "gitea",
+			path: "/project/sub/package",
+			options: []vanity.Option{
+				vanity.VCSURL("https://codeberg.org/kare"),
+			},
+			result: "https://codeberg.org/kare/project/src/branch/main/sub/package",
+		},
+		{
+			name: "sourcehut",
+			path: "/project/sub/package",
+			options: []vanity.Option{
+				vanity.VCSURL("https://git.sr.ht/~kare"),
+			},
+			result: "https://git.sr.ht/~kare/project/tree/main/item/sub/package",
+		},
+		{
+			name: "self-hosted forge",
+			path: "/project/sub/package",
+			options: []vanity.Option{
+				vanity.VCSURL("https://git.kkn.fi/kare"),
+				vanity.DefaultForge(vanity.ForgeGitLab),
+			},
+			result: "https://git.kkn.fi/kare/project/-/tree/main/sub/package",
+		},
+		{
+			name: "default branch",
+			path: "/project/sub/package",
+			options: []vanity.Option{
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.DefaultBranch("master"),
+			},
+			result: "https://github.com/kare/project/tree/master/sub/package",
+		},
+		{
+			name: "module branch and forge",
+			path: "/project/sub/package",
+			options: []vanity.Option{
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(vanity.Module{
+					Path:    "/project",
+					RepoURL: "https://git.kkn.fi/kare/project.git",
+					Forge:   vanity.ForgeGitea,
+					Branch:  "develop",
+				}),
+			},
+			result: "https://git.kkn.fi/kare/project/src/branch/develop/sub/package",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			opts := append([]vanity.Option{
+				vanity.DocsURL(vanity.DocsForgeTree),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			}, test.options...)
+			srv, err := vanity.NewHandlerWithOptions(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if location := res.Header.Get("Location"); location != test.result {
+				t.Errorf("expecting redirect to\n%v, but got\n%v", test.result, location)
+			}
+		})
+	}
+}
+
+func TestForgeInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		option vanity.Option
+	}{
+		{
+			name:   "default forge",
+			option: vanity.DefaultForge("bitbucket"),
+		},
+		{
+			name:   "module forge",
+			option: vanity.Modules(vanity.Module{Path: "/vanity", Forge: "bitbucket"}),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := vanity.NewHandlerWithOptions(test.option); err == nil {
+				t.Error("expecting error, but got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package vanity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// govanityurlsCacheMaxAge is the default cache_max_age of govanityurls in
+// seconds.
+const govanityurlsCacheMaxAge = 86400
+
+// ParseGovanityurls parses a vanity.yaml configuration file of
+// govanityurls (https://github.com/GoogleCloudPlatform/govanityurls) into
+// the equivalent configuration:
+//
+//	host: kkn.fi
+//	cache_max_age: 3600
+//	paths:
+//	  /portmidi:
+//	    repo: https://github.com/rakyll/portmidi
+//	  /hg:
+//	    repo: https://hg.example.com/hg
+//	    vcs: hg
+//	    display: "https://hg.example.com/hg _ https://hg.example.com/hg/file/tip{/dir}/{file}#L{line}"
+//
+// Host sets the domain, cache_max_age defaults to a day and display sets the
+// go-source meta tag. As in govanityurls, the VCS of GitHub repositories
+// defaults to git, and GitHub and Bitbucket repositories link their source
+// code on the master and default branches. Unknown keys are ignored.
+func ParseGovanityurls(data []byte) (*ConfigFile, error) {
+	root, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("vanity: error parsing govanityurls config: %w", err)
+	}
+	c := &ConfigFile{
+		CacheMaxAge: govanityurlsCacheMaxAge,
+	}
+	if n, ok := root.mapping["host"]; ok {
+		if n.mapping != nil {
+			return nil, fmt.Errorf("vanity: govanityurls config line %d: host is not a string", n.line)
+		}
+		c.Domain = n.value
+	}
+	if n, ok := root.mapping["cache_max_age"]; ok {
+		age, err := strconv.Atoi(n.value)
+		if err != nil || n.mapping != nil {
+			return nil, fmt.Errorf("vanity: govanityurls config line %d: cache_max_age is not an integer", n.line)
+		}
+		if age < 0 {
+			return nil, fmt.Errorf("vanity: govanityurls config line %d: cache_max_age is negative", n.line)
+		}
+		c.CacheMaxAge = age
+	}
+	paths, ok := root.mapping["paths"]
+	if !ok {
+		return c, nil
+	}
+	if paths.mapping == nil && paths.value != "" {
+		return nil, fmt.Errorf("vanity: govanityurls config line %d: paths is not a mapping", paths.line)
+	}
+	for _, path := range paths.keys {
+		m, err := govanityurlsModule(path, paths.mapping[path])
+		if err != nil {
+			return nil, err
+		}
+		c.Modules = append(c.Modules, m)
+	}
+	return c, nil
+}
+
+// govanityurlsModule returns the module of a path entry of a govanityurls
+// configuration.
+func govanityurlsModule(path string, n *yamlNode) (Module, error) {
+	if n.mapping == nil {
+		return Module{}, fmt.Errorf("vanity: govanityurls config line %d: configuration for %v is not a mapping", n.line, path)
+	}
+	field := func(name string) (string, error) {
+		f, ok := n.mapping[name]
+		if !ok {
+			return "", nil
+		}
+		if f.mapping != nil {
+			return "", fmt.Errorf("vanity: govanityurls config line %d: %v of %v is not a string", f.line, name, path)
+		}
+		return f.value, nil
+	}
+	m := Module{Path: strings.TrimSuffix(path, "/")}
+	var err error
+	if m.RepoURL, err = field("repo"); err != nil {
+		return Module{}, err
+	}
+	if m.GoSource, err = field("display"); err != nil {
+		return Module{}, err
+	}
+	if m.VCS, err = field("vcs"); err != nil {
+		return Module{}, err
+	}
+	if m.RepoURL == "" {
+		return Module{}, fmt.Errorf("vanity: govanityurls config line %d: configuration for %v has no repo", n.line, path)
+	}
+	repo := m.RepoURL
+	if m.GoSource == "" {
+		switch {
+		case strings.HasPrefix(repo, "https://github.com/"):
+			m.GoSource = fmt.Sprintf("%v %v/tree/master{/dir} %v/blob/master{/dir}/{file}#L{line}", repo, repo, repo)
+		case strings.HasPrefix(repo, "https://bitbucket.org"):
+			m.GoSource = fmt.Sprintf("%v %v/src/default{/dir} %v/src/default{/dir}/{file}#{file}-{line}", repo, repo, repo)
+		}
+	}
+	switch m.VCS {
+	case "bzr", "git", "hg", "svn":
+	case "":
+		if !strings.HasPrefix(repo, "https://github.com/") {
+			return Module{}, fmt.Errorf("vanity: govanityurls config line %d: configuration for %v: cannot infer VCS from %v", n.line, path, repo)
+		}
+		m.VCS = "git"
+	default:
+		return Module{}, fmt.Errorf("vanity: govanityurls config line %d: configuration for %v: unknown VCS %v", n.line, path, m.VCS)
+	}
+	return m, nil
+}
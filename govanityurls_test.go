@@ -0,0 +1,275 @@
+package vanity_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+// TestGovanityurls checks that vanity.yaml files of govanityurls configure
+// the same go tool responses as govanityurls.
+func TestGovanityurls(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       string
+		path         string
+		goImport     string
+		goSource     string
+		cacheControl string
+	}{
+		{
+			name: "explicit display",
+			config: "host: example.com\n" +
+				"paths:\n" +
+				"  /portmidi:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n" +
+				"    display: https://github.com/rakyll/portmidi _ _\n",
+			path:     "/portmidi",
+			goImport: "example.com/portmidi git https://github.com/rakyll/portmidi",
+			goSource: "example.com/portmidi https://github.com/rakyll/portmidi _ _",
+		},
+		{
+			name: "display GitHub inference",
+			config: "host: example.com\n" +
+				"paths:\n" +
+				"  /portmidi:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n",
+			path:     "/portmidi",
+			goImport: "example.com/portmidi git https://github.com/rakyll/portmidi",
+			goSource: "example.com/portmidi https://github.com/rakyll/portmidi https://github.com/rakyll/portmidi/tree/master{/dir} https://github.com/rakyll/portmidi/blob/master{/dir}/{file}#L{line}",
+		},
+		{
+			name: "Bitbucket Mercurial",
+			config: "host: example.com\n" +
+				"paths:\n" +
+				"  /gopdf:\n" +
+				"    repo: https://bitbucket.org/zombiezen/gopdf\n" +
+				"    vcs: hg\n",
+			path:     "/gopdf",
+			goImport: "example.com/gopdf hg https://bitbucket.org/zombiezen/gopdf",
+			goSource: "example.com/gopdf https://bitbucket.org/zombiezen/gopdf https://bitbucket.org/zombiezen/gopdf/src/default{/dir} https://bitbucket.org/zombiezen/gopdf/src/default{/dir}/{file}#{file}-{line}",
+		},
+		{
+			name: "Bitbucket Git",
+			config: "host: example.com\n" +
+				"paths:\n" +
+				"  /mygit:\n" +
+				"    repo: https://bitbucket.org/zombiezen/mygit\n" +
+				"    vcs: git\n",
+			path:     "/mygit",
+			goImport: "example.com/mygit git https://bitbucket.org/zombiezen/mygit",
+			goSource: "example.com/mygit https://bitbucket.org/zombiezen/mygit https://bitbucket.org/zombiezen/mygit/src/default{/dir} https://bitbucket.org/zombiezen/mygit/src/default{/dir}/{file}#{file}-{line}",
+		},
+		{
+			name: "subpath",
+			config: "host: example.com\n" +
+				"paths:\n" +
+				"  /portmidi:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n" +
+				"    display: https://github.com/rakyll/portmidi _ _\n",
+			path:     "/portmidi/foo",
+			goImport: "example.com/portmidi git https://github.com/rakyll/portmidi",
+			goSource: "example.com/portmidi https://github.com/rakyll/portmidi _ _",
+		},
+		{
+			name: "subpath with trailing config slash",
+			config: "host: example.com\n" +
+				"paths:\n" +
+				"  /portmidi/:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n" +
+				"    display: https://github.com/rakyll/portmidi _ _\n",
+			path:     "/portmidi/foo",
+			goImport: "example.com/portmidi git https://github.com/rakyll/portmidi",
+			goSource: "example.com/portmidi https://github.com/rakyll/portmidi _ _",
+		},
+		{
+			name: "subpath with trailing request slash",
+			config: "host: example.com\n" +
+				"paths:\n" +
+				"  /portmidi:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n" +
+				"    display: https://github.com/rakyll/portmidi _ _\n",
+			path:     "/portmidi/foo/",
+			goImport: "example.com/portmidi git https://github.com/rakyll/portmidi",
+			goSource: "example.com/portmidi https://github.com/rakyll/portmidi _ _",
+		},
+		{
+			name: "longest path",
+			config: "host: example.com\n" +
+				"paths:\n" +
+				"  /x:\n" +
+				"    repo: https://github.com/example/x\n" +
+				"  /x/y:\n" +
+				"    repo: https://github.com/example/y\n",
+			path:     "/x/y/z",
+			goImport: "example.com/x/y git https://github.com/example/y",
+			goSource: "example.com/x/y https://github.com/example/y https://github.com/example/y/tree/master{/dir} https://github.com/example/y/blob/master{/dir}/{file}#L{line}",
+		},
+		{
+			name: "request host without host",
+			config: "paths:\n" +
+				"  /portmidi:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n" +
+				"    display: https://github.com/rakyll/portmidi _ _\n",
+			path:     "/portmidi",
+			goImport: "kkn.fi/portmidi git https://github.com/rakyll/portmidi",
+			goSource: "kkn.fi/portmidi https://github.com/rakyll/portmidi _ _",
+		},
+		{
+			name: "quotes and comments",
+			config: "# vanity.yaml\n" +
+				"---\n" +
+				"host: 'example.com' # host\n" +
+				"cache_max_age: 3600\n" +
+				"paths:\n" +
+				"  \"/portmidi\":\n" +
+				"    repo: \"https://github.com/rakyll/portmidi\"\n" +
+				"\n" +
+				"    display: \"https://github.com/rakyll/portmidi _ https://github.com/rakyll/portmidi/blob/master{/dir}/{file}#L{line}\"\n",
+			path:         "/portmidi",
+			goImport:     "example.com/portmidi git https://github.com/rakyll/portmidi",
+			goSource:     "example.com/portmidi https://github.com/rakyll/portmidi _ https://github.com/rakyll/portmidi/blob/master{/dir}/{file}#L{line}",
+			cacheControl: "public, max-age=3600",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := vanity.ParseGovanityurls([]byte(test.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts, err := c.Options()
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv, err := vanity.NewHandlerWithOptions(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path+"?go-get=1", nil)
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			body, _ := io.ReadAll(res.Body)
+			for _, meta := range []string{
+				`<meta name="go-import" content="` + test.goImport + `">`,
+				`<meta name="go-source" content="` + test.goSource + `">`,
+			} {
+				if !strings.Contains(string(body), meta) {
+					t.Errorf("expected body to contain %v, but got:\n%s", meta, body)
+				}
+			}
+			cacheControl := test.cacheControl
+			if cacheControl == "" {
+				cacheControl = "public, max-age=86400"
+			}
+			if got := res.Header.Get("Cache-Control"); got != cacheControl {
+				t.Errorf("expected Cache-Control %q, but got %q", cacheControl, got)
+			}
+		})
+	}
+}
+
+func TestGovanityurlsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "missing vcs",
+			config: "paths:\n" +
+				"  /missingvcs:\n" +
+				"    repo: https://bitbucket.org/zombiezen/gopdf\n",
+			err: "configuration for /missingvcs: cannot infer VCS from https://bitbucket.org/zombiezen/gopdf",
+		},
+		{
+			name: "unknown vcs",
+			config: "paths:\n" +
+				"  /unknownvcs:\n" +
+				"    repo: https://bitbucket.org/zombiezen/gopdf\n" +
+				"    vcs: xyzzy\n",
+			err: "configuration for /unknownvcs: unknown VCS xyzzy",
+		},
+		{
+			name: "negative cache_max_age",
+			config: "cache_max_age: -1\n" +
+				"paths:\n" +
+				"  /portmidi:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n",
+			err: "cache_max_age is negative",
+		},
+		{
+			name: "missing repo",
+			config: "paths:\n" +
+				"  /portmidi:\n" +
+				"    vcs: git\n",
+			err: "configuration for /portmidi has no repo",
+		},
+		{
+			name: "sequence",
+			config: "paths:\n" +
+				"  - /portmidi\n",
+			err: "line 2: sequences are not supported",
+		},
+		{
+			name: "indentation",
+			config: "host: example.com\n" +
+				"  paths:\n",
+			err: "line 2: unexpected indentation",
+		},
+		{
+			name: "duplicate path",
+			config: "paths:\n" +
+				"  /portmidi:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n" +
+				"  /portmidi:\n" +
+				"    repo: https://github.com/rakyll/portmidi\n",
+			err: `line 4: duplicate key "/portmidi"`,
+		},
+		{
+			name:   "unterminated quote",
+			config: "host: \"example.com\n",
+			err:    "line 1: invalid double quoted scalar",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := vanity.ParseGovanityurls([]byte(test.config))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing %q, but got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestLoadFileGovanityurls(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "vanity.yaml")
+	config := "host: example.com\n" +
+		"paths:\n" +
+		"  /portmidi:\n" +
+		"    repo: https://github.com/rakyll/portmidi\n"
+	if err := os.WriteFile(name, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := vanity.LoadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `<meta name="go-import" content="example.com/portmidi git https://github.com/rakyll/portmidi">`
+	if got := goImport(t, srv, "/portmidi"); got != want {
+		t.Errorf("expected %v, but got %v", want, got)
+	}
+}
@@ -0,0 +1,251 @@
+package vanity
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Revision sources.
+const (
+	// SourceReload revision was read from the configuration file.
+	SourceReload = "reload"
+	// SourceAdmin revision was written by the admin API.
+	SourceAdmin = "admin"
+	// SourceRollback revision restored a previous revision.
+	SourceRollback = "rollback"
+)
+
+// errUnknownRevision is returned for revision IDs not in the history.
+var errUnknownRevision = errors.New("vanity: unknown revision")
+
+// Revision is an accepted configuration of a Reloader.
+type Revision struct {
+	// ID is the sequence number of the revision starting from 1.
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	// Author is the admin token name of the change, or empty for reloads of
+	// the configuration file.
+	Author string `json:"author,omitempty"`
+	// Source is SourceReload, SourceAdmin or SourceRollback.
+	Source string `json:"source"`
+	// Rollback is the ID of the revision restored by a rollback.
+	Rollback int `json:"rollback,omitempty"`
+	// Config is the contents of the configuration file.
+	Config string `json:"config,omitempty"`
+}
+
+// History is an append-only store of the configuration revisions of a
+// Reloader. Revisions are stored in a file as JSON lines. See
+// Reloader.SetHistory().
+type History struct {
+	mu        sync.Mutex
+	f         *os.File
+	revisions []Revision
+}
+
+// OpenHistory opens or creates the history file name.
+func OpenHistory(name string) (*History, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("vanity: error opening history: %w", err)
+	}
+	h := &History{f: f}
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 64<<20)
+	for line := 1; s.Scan(); line++ {
+		var rev Revision
+		if err := json.Unmarshal(s.Bytes(), &rev); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("vanity: error parsing history %v:%v: %w", name, line, err)
+		}
+		h.revisions = append(h.revisions, rev)
+	}
+	if err := s.Err(); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("vanity: error reading history: %w", err)
+	}
+	return h, nil
+}
+
+// Close closes the history file.
+func (h *History) Close() error {
+	return h.f.Close()
+}
+
+// Revisions returns all revisions from the oldest to the latest.
+func (h *History) Revisions() []Revision {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Revision(nil), h.revisions...)
+}
+
+// Revision returns the revision id.
+func (h *History) Revision(id int) (Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, rev := range h.revisions {
+		if rev.ID == id {
+			return rev, nil
+		}
+	}
+	return Revision{}, fmt.Errorf("%w %v", errUnknownRevision, id)
+}
+
+// add appends rev to the history unless its configuration is the same as
+// the one of the latest revision. The ID and time of rev are set.
+func (h *History) add(rev Revision) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.revisions); n > 0 {
+		latest := h.revisions[n-1]
+		if latest.Config == rev.Config && rev.Source != SourceRollback {
+			return nil
+		}
+		rev.ID = latest.ID + 1
+	} else {
+		rev.ID = 1
+	}
+	rev.Time = time.Now().UTC()
+	b, err := json.Marshal(rev)
+	if err != nil {
+		return fmt.Errorf("vanity: error encoding revision: %w", err)
+	}
+	if _, err := h.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("vanity: error writing history: %w", err)
+	}
+	if err := h.f.Sync(); err != nil {
+		return fmt.Errorf("vanity: error writing history: %w", err)
+	}
+	h.revisions = append(h.revisions, rev)
+	return nil
+}
+
+// Diff returns the changes of the configuration from revision from to
+// revision to as a unified diff with three lines of context.
+func (h *History) Diff(from, to int) (string, error) {
+	a, err := h.Revision(from)
+	if err != nil {
+		return "", err
+	}
+	b, err := h.Revision(to)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(fmt.Sprintf("revision %v", a.ID), fmt.Sprintf("revision %v", b.ID), a.Config, b.Config), nil
+}
+
+// diffContext is the number of unchanged lines around changes of a diff.
+const diffContext = 3
+
+// diffLine is a line of a diff. Op is ' ' for unchanged, '-' for removed
+// and '+' for added lines.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// splitLines splits s into lines without the line endings.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the line changes from a to b by their longest common
+// subsequence.
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return lines
+}
+
+// unifiedDiff returns the unified diff from a to b, or an empty string if
+// they are equal.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+	var buf bytes.Buffer
+	for start := 0; start < len(lines); {
+		// Find the next change and the end of its hunk, which extends
+		// while changes are at most twice the context apart.
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		end := first
+		for k := first; k < len(lines) && k-end <= 2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k + 1
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(end+diffContext, len(lines))
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %v\n+++ %v\n", nameA, nameB)
+		}
+		// Line numbers of the hunk start from 1.
+		lineA, lineB := 1, 1
+		for _, l := range lines[:from] {
+			if l.op != '+' {
+				lineA++
+			}
+			if l.op != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				countA++
+			}
+			if l.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%v,%v +%v,%v @@\n", lineA, countA, lineB, countB)
+		for _, l := range lines[from:to] {
+			fmt.Fprintf(&buf, "%c%v\n", l.op, l.text)
+		}
+		start = to
+	}
+	return buf.String()
+}
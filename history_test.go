@@ -0,0 +1,224 @@
+package vanity_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	name := writeConfigFile(t, dir, "{\n\t\"vcsURL\": \"https://github.com/kare\",\n\t\"modules\": [\n\t\t{\"path\": \"/x\"}\n\t]\n}\n")
+	r, err := vanity.NewReloader(name, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	historyFile := filepath.Join(dir, "history.jsonl")
+	h, err := vanity.OpenHistory(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetHistory(h)
+
+	// Unchanged configuration is not recorded again.
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	writeConfigFile(t, dir, "{\n\t\"vcsURL\": \"https://gitlab.com/kare\",\n\t\"modules\": [\n\t\t{\"path\": \"/x\"}\n\t]\n}\n")
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	// Invalid configuration is not recorded.
+	writeConfigFile(t, dir, `{"vcs": "cvs"}`)
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected reload of invalid configuration to fail")
+	}
+	revisions := h.Revisions()
+	if len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions, but got %+v", revisions)
+	}
+	for i, rev := range revisions {
+		if rev.ID != i+1 || rev.Source != vanity.SourceReload || rev.Time.IsZero() {
+			t.Errorf("unexpected revision %+v", rev)
+		}
+	}
+
+	diff, err := h.Diff(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantDiff = `--- revision 1
++++ revision 2
+@@ -1,5 +1,5 @@
+ {
+-	"vcsURL": "https://github.com/kare",
++	"vcsURL": "https://gitlab.com/kare",
+ 	"modules": [
+ 		{"path": "/x"}
+ 	]
+`
+	if diff != wantDiff {
+		t.Errorf("expected diff:\n%v\nbut got:\n%v", wantDiff, diff)
+	}
+	if diff, err := h.Diff(2, 2); err != nil || diff != "" {
+		t.Errorf("expected empty diff of a revision to itself, but got %q, %v", diff, err)
+	}
+	if _, err := h.Diff(1, 3); err == nil {
+		t.Error("expected an error of an unknown revision")
+	}
+
+	if err := r.Rollback(1, "kare"); err != nil {
+		t.Fatal(err)
+	}
+	const want = `<meta name="go-import" content="kkn.fi/y git https://github.com/kare/y">`
+	if got := goImport(t, r, "/y"); got != want {
+		t.Errorf("expected %v after rollback, but got %v", want, got)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != revisions[0].Config {
+		t.Errorf("expected configuration file of revision 1 after rollback, but got %s", b)
+	}
+	if err := r.Rollback(7, "kare"); err == nil {
+		t.Error("expected an error of a rollback to an unknown revision")
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revisions are persisted.
+	h, err = vanity.OpenHistory(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	revisions = h.Revisions()
+	if len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions, but got %+v", revisions)
+	}
+	rollback := revisions[2]
+	if rollback.ID != 3 || rollback.Author != "kare" || rollback.Source != vanity.SourceRollback || rollback.Rollback != 1 || rollback.Config != revisions[0].Config {
+		t.Errorf("unexpected rollback revision %+v", rollback)
+	}
+}
+
+func TestRollbackInvalid(t *testing.T) {
+	dir := t.TempDir()
+	// The static directory of revision 1 is removed later.
+	static := filepath.Join(dir, "static")
+	if err := os.Mkdir(static, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	name := writeConfigFile(t, dir, `{"staticDir": "static"}`)
+	r, err := vanity.NewReloader(name, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := vanity.OpenHistory(filepath.Join(dir, "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	r.SetHistory(h)
+	writeConfigFile(t, dir, `{}`)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(static); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rollback(1, "kare"); err == nil || !strings.Contains(err.Error(), "static dir") {
+		t.Errorf("expected an error of the missing static dir, but got %v", err)
+	}
+	if b, err := os.ReadFile(name); err != nil || string(b) != `{}` {
+		t.Errorf("expected configuration file to be unchanged, but got %s, %v", b, err)
+	}
+	if n := len(h.Revisions()); n != 2 {
+		t.Errorf("expected 2 revisions, but got %v", n)
+	}
+}
+
+func TestOpenHistoryMalformed(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := os.WriteFile(name, []byte(`{"id": 1, "source": "reload", "config": "{}"}`+"\n{\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := vanity.OpenHistory(name)
+	if err == nil || !strings.Contains(err.Error(), "history.jsonl:2") {
+		t.Errorf("expected an error of line 2, but got %v", err)
+	}
+}
+
+func TestHistoryDiffHunks(t *testing.T) {
+	lines := func(changes map[int]string) string {
+		var b strings.Builder
+		for i := 1; i <= 20; i++ {
+			if s, ok := changes[i]; ok {
+				b.WriteString(s)
+				continue
+			}
+			fmt.Fprintf(&b, "line %v\n", i)
+		}
+		return b.String()
+	}
+	name := filepath.Join(t.TempDir(), "history.jsonl")
+	var history bytes.Buffer
+	enc := json.NewEncoder(&history)
+	for i, config := range []string{
+		lines(nil),
+		lines(map[int]string{2: "", 10: "line ten\n", 19: "line 19\nline 19.5\n"}),
+	} {
+		if err := enc.Encode(vanity.Revision{ID: i + 1, Source: vanity.SourceReload, Config: config}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(name, history.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h, err := vanity.OpenHistory(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	diff, err := h.Diff(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `--- revision 1
++++ revision 2
+@@ -1,5 +1,4 @@
+ line 1
+-line 2
+ line 3
+ line 4
+ line 5
+@@ -7,7 +6,7 @@
+ line 7
+ line 8
+ line 9
+-line 10
++line ten
+ line 11
+ line 12
+ line 13
+@@ -17,4 +16,5 @@
+ line 17
+ line 18
+ line 19
++line 19.5
+ line 20
+`
+	if diff != want {
+		t.Errorf("expected diff:\n%v\nbut got:\n%v", want, diff)
+	}
+}
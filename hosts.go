@@ -0,0 +1,247 @@
+package vanity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hosts is a handler serving several vanity domains from a directory of
+// configuration files, one per domain. The domain of a configuration file is
+// its name without the extension, such as kkn.fi.json or go.example.com.yaml.
+// Requests are dispatched on their host, and hosts without a configuration
+// file are not found. Every domain has its own modules, static directory,
+// index page and robots.txt, and is reloaded independently of the others.
+// See ReadConfigFile().
+type Hosts struct {
+	dir      string
+	override func(*ConfigFile) error
+	opts     []Option
+	log      Logger
+
+	mu      sync.RWMutex
+	tenants map[string]*Reloader
+	// failed contains the modification time and size of configuration
+	// files that were added to the directory but are invalid.
+	failed map[string]os.FileInfo
+}
+
+// NewHosts returns a Hosts handler of the configuration files in dir. An
+// override function, if not nil, is called with every configuration read
+// from the files before the handler is built. Given options are applied
+// after the options of the files. All initial configurations must be valid.
+// The domain field of a configuration file may be omitted, but it must match
+// the name of the file if set.
+func NewHosts(dir string, override func(*ConfigFile) error, opts ...Option) (*Hosts, error) {
+	c, err := optionsConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	h := &Hosts{
+		dir:      dir,
+		override: override,
+		opts:     opts,
+		log:      c.Log,
+		tenants:  make(map[string]*Reloader),
+		failed:   make(map[string]os.FileInfo),
+	}
+	if h.log == nil {
+		h.log = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	files, err := h.configFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("vanity: no configuration files in %v", dir)
+	}
+	var errs []error
+	for domain, name := range files {
+		r, err := h.newTenant(domain, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vanity: host %v: %w", domain, err))
+			continue
+		}
+		h.tenants[domain] = r
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// configFiles returns the configuration files of the directory by domain.
+func (h *Hosts) configFiles() (map[string]string, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, fmt.Errorf("vanity: error reading config directory: %w", err)
+	}
+	files := make(map[string]string)
+	for _, e := range entries {
+		name := e.Name()
+		ext := filepath.Ext(name)
+		if e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		switch ext {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+		domain := strings.ToLower(strings.TrimSuffix(name, ext))
+		if other, ok := files[domain]; ok {
+			return nil, fmt.Errorf("vanity: config files %v and %v have the same domain %v", other, name, domain)
+		}
+		files[domain] = filepath.Join(h.dir, name)
+	}
+	return files, nil
+}
+
+// newTenant returns a Reloader of the configuration file name of domain.
+func (h *Hosts) newTenant(domain, name string) (*Reloader, error) {
+	override := func(c *ConfigFile) error {
+		switch {
+		case c.Domain == "":
+			c.Domain = domain
+		case !strings.EqualFold(c.Domain, domain):
+			return fmt.Errorf("vanity: config file %v has domain %v", name, c.Domain)
+		}
+		if h.override != nil {
+			return h.override(c)
+		}
+		return nil
+	}
+	return NewReloader(name, override, h.opts...)
+}
+
+// hostname returns the lower case host of the request without the port and
+// a trailing dot.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func (h *Hosts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	tenant := h.tenants[hostname(r.Host)]
+	h.mu.RUnlock()
+	if tenant == nil {
+		http.NotFound(w, r)
+		return
+	}
+	tenant.ServeHTTP(w, r)
+}
+
+// Domains returns the sorted domains served.
+func (h *Hosts) Domains() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	domains := make([]string, 0, len(h.tenants))
+	for domain := range h.tenants {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Reload reloads the configuration file of domain. Other domains are not
+// affected, and domain keeps its current configuration if the new one is
+// invalid.
+func (h *Hosts) Reload(domain string) error {
+	h.mu.RLock()
+	tenant := h.tenants[strings.ToLower(domain)]
+	h.mu.RUnlock()
+	if tenant == nil {
+		return fmt.Errorf("vanity: unknown host %q", domain)
+	}
+	return tenant.Reload()
+}
+
+// scan adds the domains of new configuration files and removes the domains
+// whose configuration file was removed. A new configuration file that is
+// invalid is tried again when it changes.
+func (h *Hosts) scan() {
+	files, err := h.configFiles()
+	if err != nil {
+		h.log.Printf("%v", err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for domain := range h.tenants {
+		if _, ok := files[domain]; !ok {
+			delete(h.tenants, domain)
+			h.log.Printf("vanity: removed host %v", domain)
+		}
+	}
+	for domain := range h.failed {
+		if _, ok := files[domain]; !ok {
+			delete(h.failed, domain)
+		}
+	}
+	for domain, name := range files {
+		if _, ok := h.tenants[domain]; ok {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			h.log.Printf("vanity: error reading config file: %v", err)
+			continue
+		}
+		if prev, ok := h.failed[domain]; ok && prev.ModTime().Equal(info.ModTime()) && prev.Size() == info.Size() {
+			continue
+		}
+		r, err := h.newTenant(domain, name)
+		if err != nil {
+			h.failed[domain] = info
+			h.log.Printf("vanity: host %v: %v", domain, err)
+			continue
+		}
+		delete(h.failed, domain)
+		h.tenants[domain] = r
+		h.log.Printf("vanity: added host %v", domain)
+	}
+}
+
+// Watch polls the configuration directory every interval until ctx is done.
+// Added and removed configuration files add and remove domains, and changed
+// files are reloaded like with Reloader.Watch(). Errors of a domain are
+// logged with its logger.
+func (h *Hosts) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		h.scan()
+		h.mu.RLock()
+		tenants := make(map[string]*Reloader, len(h.tenants))
+		for domain, r := range h.tenants {
+			tenants[domain] = r
+		}
+		h.mu.RUnlock()
+		for domain, r := range tenants {
+			reloaded, err := r.reloadChanged()
+			switch {
+			case err != nil:
+				r.logger().Printf("vanity: host %v: %v", domain, err)
+			case reloaded:
+				r.logger().Printf("vanity: reloaded host %v", domain)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package vanity_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kkn.fi/vanity"
+)
+
+func TestHostsWatchIntegration(t *testing.T) {
+	integrationTest(t)
+	dir := t.TempDir()
+	writeHostFile(t, dir, "a.example.com.json", `{"vcsURL": "https://github.com/a"}`)
+	writeHostFile(t, dir, "b.example.com.json", `{"vcsURL": "https://github.com/b"}`)
+	h, err := vanity.NewHosts(dir, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Watch(ctx, 10*time.Millisecond)
+
+	eventually := func(host, want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for hostGoImport(t, h, host, "/x") != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %v of %v, but got %v", want, host, hostGoImport(t, h, host, "/x"))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	writeHostFile(t, dir, "b.example.com.json", `{"vcsURL": "https://gitlab.com/b"}`)
+	eventually("b.example.com", `<meta name="go-import" content="b.example.com/x git https://gitlab.com/b/x">`)
+
+	writeHostFile(t, dir, "c.example.com.json", `{"vcsURL": "https://github.com/c"}`)
+	eventually("c.example.com", `<meta name="go-import" content="c.example.com/x git https://github.com/c/x">`)
+
+	if err := os.Remove(filepath.Join(dir, "a.example.com.json")); err != nil {
+		t.Fatal(err)
+	}
+	eventually("a.example.com", "")
+	eventually("c.example.com", `<meta name="go-import" content="c.example.com/x git https://github.com/c/x">`)
+}
@@ -0,0 +1,184 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func writeHostFile(t *testing.T, dir, name, config string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hostGoImport(t *testing.T, h http.Handler, host, path string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "https://"+host+path+"?go-get=1", nil)
+	h.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Result().Body)
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.Contains(line, `name="go-import"`) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+func TestHosts(t *testing.T) {
+	dir := t.TempDir()
+	writeHostFile(t, dir, "kkn.fi.json", `{"vcsURL": "https://github.com/kare", "robotsTxt": "robots.txt"}`)
+	writeHostFile(t, dir, "robots.txt", "kkn.fi robots\n")
+	writeHostFile(t, dir, "go.example.com.yaml", "paths:\n  /x:\n    repo: https://github.com/example/x\n")
+	writeHostFile(t, dir, "README.md", "not a configuration file")
+	h, err := vanity.NewHosts(dir, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(h.Domains(), " "), "go.example.com kkn.fi"; got != want {
+		t.Errorf("expected domains %v, but got %v", want, got)
+	}
+	tests := []struct {
+		name string
+		host string
+		path string
+		want string
+	}{
+		{
+			name: "vcs url",
+			host: "kkn.fi",
+			path: "/vanity",
+			want: `<meta name="go-import" content="kkn.fi/vanity git https://github.com/kare/vanity">`,
+		},
+		{
+			name: "host with port",
+			host: "KKN.fi:8080",
+			path: "/vanity",
+			want: `<meta name="go-import" content="kkn.fi/vanity git https://github.com/kare/vanity">`,
+		},
+		{
+			name: "govanityurls",
+			host: "go.example.com",
+			path: "/x/sub",
+			want: `<meta name="go-import" content="go.example.com/x git https://github.com/example/x">`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := hostGoImport(t, h, test.host, test.path); got != test.want {
+				t.Errorf("expected %v, but got %v", test.want, got)
+			}
+		})
+	}
+
+	t.Run("unknown host", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://example.org/x", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected response status 404, but got %v", rec.Code)
+		}
+	})
+
+	t.Run("robots.txt", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://kkn.fi/robots.txt", nil))
+		if body := rec.Body.String(); body != "kkn.fi robots\n" {
+			t.Errorf("expected robots.txt of kkn.fi, but got %q", body)
+		}
+	})
+}
+
+func TestHostsReload(t *testing.T) {
+	dir := t.TempDir()
+	writeHostFile(t, dir, "a.example.com.json", `{"vcsURL": "https://github.com/a"}`)
+	writeHostFile(t, dir, "b.example.com.json", `{"vcsURL": "https://github.com/b"}`)
+	h, err := vanity.NewHosts(dir, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		a = `<meta name="go-import" content="a.example.com/x git https://github.com/a/x">`
+		b = `<meta name="go-import" content="b.example.com/x git https://gitlab.com/b/x">`
+	)
+
+	// Invalid configuration of a domain keeps its current handler.
+	writeHostFile(t, dir, "a.example.com.json", `{"vcs": "cvs"}`)
+	if err := h.Reload("a.example.com"); err == nil {
+		t.Error("expected reload of invalid configuration to fail")
+	}
+	writeHostFile(t, dir, "b.example.com.json", `{"vcsURL": "https://gitlab.com/b"}`)
+	if err := h.Reload("b.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := hostGoImport(t, h, "a.example.com", "/x"); got != a {
+		t.Errorf("expected %v, but got %v", a, got)
+	}
+	if got := hostGoImport(t, h, "b.example.com", "/x"); got != b {
+		t.Errorf("expected %v, but got %v", b, got)
+	}
+	if err := h.Reload("c.example.com"); err == nil {
+		t.Error("expected reload of an unknown host to fail")
+	}
+}
+
+func TestNewHostsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name: "empty directory",
+			err:  "vanity: no configuration files in",
+		},
+		{
+			name: "domain mismatch",
+			files: map[string]string{
+				"kkn.fi.json": `{"domain": "example.com"}`,
+			},
+			err: "has domain example.com",
+		},
+		{
+			name: "same domain",
+			files: map[string]string{
+				"kkn.fi.json": `{}`,
+				"kkn.fi.yaml": "host: kkn.fi\n",
+			},
+			err: "have the same domain kkn.fi",
+		},
+		{
+			name: "invalid configuration",
+			files: map[string]string{
+				"kkn.fi.json": `{"forge": "bitbucket"}`,
+			},
+			err: `vanity: host kkn.fi: vanity: unknown forge "bitbucket"`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for name, config := range test.files {
+				writeHostFile(t, dir, name, config)
+			}
+			_, err := vanity.NewHosts(dir, nil)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing %q, but got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package vanity
+
+import (
+	"html/template"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultIndexTemplate is the built-in index page template listing all
+// configured modules. It is executed with an *IndexPage.
+var DefaultIndexTemplate = templates.Lookup("index.html")
+
+// IndexPage describes the configured modules for the index page.
+type IndexPage struct {
+	// Domain is the vanity server domain, such as kkn.fi.
+	Domain string
+	// Groups contains the modules grouped by their path prefix.
+	Groups []ModuleGroup
+	// Data is the user supplied data given to IndexTemplate().
+	Data interface{}
+}
+
+// ModuleGroup is a group of modules sharing a path prefix.
+type ModuleGroup struct {
+	// Prefix is the parent directory of the module paths, such as cmd, or
+	// empty for top-level modules.
+	Prefix  string
+	Modules []*ModulePage
+}
+
+// indexPage returns the index page data of the configured modules.
+func (h *handler) indexPage(r *http.Request, domain string) *IndexPage {
+	p := &IndexPage{
+		Domain: domain,
+		Data:   h.indexData,
+	}
+	groups := make(map[string]int)
+	for _, page := range h.modulePages(r, domain) {
+		prefix := strings.TrimPrefix(path.Dir(page.Path), "/")
+		i, ok := groups[prefix]
+		if !ok {
+			i = len(p.Groups)
+			groups[prefix] = i
+			p.Groups = append(p.Groups, ModuleGroup{Prefix: prefix})
+		}
+		p.Groups[i].Modules = append(p.Groups[i].Modules, page)
+	}
+	sort.SliceStable(p.Groups, func(i, j int) bool {
+		return p.Groups[i].Prefix < p.Groups[j].Prefix
+	})
+	return p
+}
+
+// serveIndex serves the index page. IndexPageHandler() takes precedence over
+// IndexTemplate(), which takes precedence over index.html in the static
+// directory. Without any of them the built-in index page is rendered.
+func (h *handler) serveIndex(w http.ResponseWriter, r *http.Request, domain string) {
+	switch {
+	case h.indexPageHandler != nil:
+		h.indexPageHandler.ServeHTTP(w, r)
+	case h.indexTemplate == nil && h.static != nil:
+		DefaultIndexPageHandler(h.static.path+"/index.html").ServeHTTP(w, r)
+	default:
+		tmpl := h.indexTemplate
+		if tmpl == nil {
+			tmpl = DefaultIndexTemplate
+		}
+		h.render(w, tmpl, h.indexPage(r, domain))
+	}
+}
+
+// IndexTemplate sets the template of the generated index page. The template
+// is executed with an *IndexPage whose Data field is set to data. If tmpl is
+// nil, DefaultIndexTemplate is used.
+func IndexTemplate(tmpl *template.Template, data interface{}) Option {
+	return func(h http.Handler) error {
+		v := h.(*handler)
+		if tmpl == nil {
+			tmpl = DefaultIndexTemplate
+		}
+		v.config.IndexTemplate = tmpl
+		v.config.IndexData = data
+		return nil
+	}
+}
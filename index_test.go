@@ -0,0 +1,139 @@
+package vanity_test
+
+import (
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestIndexPage(t *testing.T) {
+	modules := []vanity.Module{
+		{Path: "/vanity", Description: "Vanity import paths."},
+		{Path: "/cmd/tcpproxy", Description: "TCP proxy."},
+		{Path: "/cmd/healthcheck"},
+		{Path: "/gist"},
+	}
+	tests := []struct {
+		name    string
+		options []vanity.Option
+		result  string
+	}{
+		{
+			name: "generated without static dir",
+			result: `<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>kkn.fi</title>
+    <link rel="canonical" href="https://kkn.fi/">
+    <meta name="description" content="Go modules hosted at kkn.fi.">
+    <meta property="og:type" content="website">
+    <meta property="og:site_name" content="kkn.fi">
+    <meta property="og:title" content="kkn.fi">
+    <meta property="og:description" content="Go modules hosted at kkn.fi.">
+    <meta property="og:url" content="https://kkn.fi/">
+    <meta name="twitter:card" content="summary">
+    <meta name="twitter:title" content="kkn.fi">
+    <meta name="twitter:description" content="Go modules hosted at kkn.fi.">
+  </head>
+  <body>
+    <h1>kkn.fi</h1>
+    <form action="/search" method="get">
+      <input type="search" name="q" aria-label="Search modules">
+      <button type="submit">Search</button>
+    </form>
+    <section>
+      <dl>
+        <dt><a href="/gist">kkn.fi/gist</a></dt>
+        <dd><a href="https://pkg.go.dev/kkn.fi/gist">Documentation</a> <a href="https://github.com/kare/gist">Repository</a></dd>
+        <dt><a href="/vanity">kkn.fi/vanity</a></dt>
+        <dd>Vanity import paths.</dd>
+        <dd><a href="https://pkg.go.dev/kkn.fi/vanity">Documentation</a> <a href="https://github.com/kare/vanity">Repository</a></dd>
+      </dl>
+    </section>
+    <section>
+      <h2>kkn.fi/cmd</h2>
+      <dl>
+        <dt><a href="/cmd/healthcheck">kkn.fi/cmd/healthcheck</a></dt>
+        <dd><a href="https://pkg.go.dev/kkn.fi/cmd/healthcheck">Documentation</a> <a href="https://github.com/kare/healthcheck">Repository</a></dd>
+        <dt><a href="/cmd/tcpproxy">kkn.fi/cmd/tcpproxy</a></dt>
+        <dd>TCP proxy.</dd>
+        <dd><a href="https://pkg.go.dev/kkn.fi/cmd/tcpproxy">Documentation</a> <a href="https://github.com/kare/tcpproxy">Repository</a></dd>
+      </dl>
+    </section>
+  </body>
+</html>
+`,
+		},
+		{
+			name: "custom template and data take precedence over static dir",
+			options: []vanity.Option{
+				vanity.StaticDir("testdata", "/.static/"),
+				vanity.IndexTemplate(
+					template.Must(template.New("index").Parse(`{{.Data}}:{{range .Groups}}{{.Prefix}}={{len .Modules}};{{end}}`)),
+					"Welcome",
+				),
+			},
+			result: "Welcome:=2;cmd=2;",
+		},
+		{
+			name: "index page handler",
+			options: []vanity.Option{
+				vanity.IndexPageHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.WriteString(w, "handler")
+				})),
+			},
+			result: "handler",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+"/", nil)
+			opts := append([]vanity.Option{
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(modules...),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			}, test.options...)
+			srv, err := vanity.NewHandlerWithOptions(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("expected response status %v, but got %v", http.StatusOK, res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if string(body) != test.result {
+				t.Errorf("expecting body\n%v, but got\n%s", test.result, body)
+			}
+		})
+	}
+}
+
+func TestIndexPageWithoutModules(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, addr, nil)
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.Log(log.New(io.Discard, "", 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Result().Body)
+	if !strings.Contains(string(body), "No modules configured.") {
+		t.Errorf("expecting empty module list, but got\n%s", body)
+	}
+}
@@ -0,0 +1,117 @@
+package vanity
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+)
+
+// DefaultLandingPageTemplate is the built-in module landing page template. It
+// is executed with a *ModulePage.
+var DefaultLandingPageTemplate = templates.Lookup("landing.html")
+
+// ModulePage describes a module for HTML pages and the JSON API.
+type ModulePage struct {
+	// Path is the path of the request on the vanity server, such as
+	// /project/sub/pkg.
+	Path string `json:"path"`
+	// ImportPath is the import path of the request, such as
+	// kkn.fi/project/sub/pkg.
+	ImportPath string `json:"importPath"`
+	// ImportPrefix is the import path prefix of the go-import meta tag.
+	ImportPrefix string `json:"importPrefix"`
+	// ModuleRoot is the import path of the module, such as kkn.fi/project.
+	ModuleRoot string `json:"moduleRoot"`
+	// VCS is the version control system type, such as git.
+	VCS string `json:"vcs"`
+	// RepoURL is the repository URL.
+	RepoURL string `json:"repoURL"`
+	// DocsURL is the documentation URL browsers would be redirected to.
+	DocsURL string `json:"docsURL"`
+	// State of the module.
+	State ModuleState `json:"state"`
+	// Description of the module, if configured.
+	Description string `json:"description,omitempty"`
+	// License of the module, such as BSD-3-Clause, if configured.
+	License string `json:"license,omitempty"`
+	// Version is the requested version or the latest version when known.
+	Version string `json:"version,omitempty"`
+	// Versions of the module from the highest to the lowest, if known.
+	Versions []Version `json:"versions,omitempty"`
+	// Command reports whether the import path looks like a main package
+	// installable with go install.
+	Command bool `json:"command"`
+	// Private reports whether the module is hidden from crawlers.
+	Private bool `json:"private,omitempty"`
+	// GoSource is the content of the go-source meta tag linking to the
+	// source code in the repository tree view.
+	GoSource string `json:"-"`
+}
+
+// page returns the page data of t without versions.
+func (t *target) page() *ModulePage {
+	p := &ModulePage{
+		Path:         t.path,
+		ImportPath:   t.importPath,
+		ImportPrefix: t.importPrefix(),
+		ModuleRoot:   t.moduleRoot,
+		VCS:          t.vcs,
+		RepoURL:      t.repoURL,
+		DocsURL:      t.browserURL(),
+		Version:      t.version,
+		State:        StateActive,
+		Command:      isCommand(t.importPath),
+		GoSource:     t.goSource(),
+	}
+	if t.module != nil {
+		p.Description = t.module.Description
+		p.License = t.module.License
+		p.Private = t.module.Private
+		if t.module.State != "" {
+			p.State = t.module.State
+		}
+	}
+	return p
+}
+
+// modulePage returns the page data of t including the versions of the
+// module.
+func (h *handler) modulePage(t *target) *ModulePage {
+	p := t.page()
+	versions, err := h.versions(t)
+	if err != nil && !errors.Is(err, errNoRepoDir) {
+		h.log.Printf("%v", err)
+	}
+	p.Versions = versions
+	if latest, ok := latestVersion(versions); ok && p.Version == "" {
+		p.Version = latest.Version
+	}
+	return p
+}
+
+// isCommand reports whether importPath has a cmd path component.
+func isCommand(importPath string) bool {
+	for _, c := range pathComponents(importPath) {
+		if c == "cmd" {
+			return true
+		}
+	}
+	return false
+}
+
+// LandingPage renders an HTML landing page for browsers instead of
+// redirecting them to the documentation URL. The page shows the import path,
+// go get and go install commands, repository and documentation links, and
+// module license and version when known. If tmpl is nil,
+// DefaultLandingPageTemplate is used. The template is executed with a
+// *ModulePage.
+func LandingPage(tmpl *template.Template) Option {
+	return func(h http.Handler) error {
+		v := h.(*handler)
+		if tmpl == nil {
+			tmpl = DefaultLandingPageTemplate
+		}
+		v.config.LandingPage = tmpl
+		return nil
+	}
+}
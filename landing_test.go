@@ -0,0 +1,129 @@
+package vanity_test
+
+import (
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestLandingPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		template *template.Template
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{
+			name:   "library",
+			path:   "/project/sub/pkg",
+			status: http.StatusOK,
+			contains: []string{
+				`<meta name="go-import" content="kkn.fi/project git https://github.com/kare/project">`,
+				`<title>kkn.fi/project/sub/pkg</title>`,
+				`<p>Project does things.</p>`,
+				`go get kkn.fi/project/sub/pkg`,
+				`<a href="https://github.com/kare/project">`,
+				`<a href="https://pkg.go.dev/kkn.fi/project/sub/pkg">`,
+				`<dd>BSD-3-Clause</dd>`,
+			},
+			excludes: []string{
+				`go install`,
+			},
+		},
+		{
+			name:   "command with version",
+			path:   "/cmd/tcpproxy@v1.0.0",
+			status: http.StatusOK,
+			contains: []string{
+				`go get kkn.fi/cmd/tcpproxy@v1.0.0`,
+				`go install kkn.fi/cmd/tcpproxy@v1.0.0`,
+				`<dd>v1.0.0</dd>`,
+			},
+			excludes: []string{
+				`License`,
+			},
+		},
+		{
+			name:     "custom template",
+			path:     "/vanity",
+			template: template.Must(template.New("custom").Parse(`{{.ImportPath}} at {{.RepoURL}}`)),
+			status:   http.StatusOK,
+			contains: []string{
+				`kkn.fi/vanity at https://github.com/kare/vanity`,
+			},
+		},
+		{
+			name:     "template error",
+			path:     "/vanity",
+			template: template.Must(template.New("error").Parse(`{{.Unknown}}`)),
+			status:   http.StatusInternalServerError,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv, err := vanity.NewHandlerWithOptions(
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(vanity.Module{
+					Path:        "/project",
+					Description: "Project does things.",
+					License:     "BSD-3-Clause",
+				}),
+				vanity.LandingPage(test.template),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != test.status {
+				t.Errorf("expected response status %v, but got %v", test.status, res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			for _, s := range test.contains {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("expecting\n%v be contained in\n%s", s, body)
+				}
+			}
+			for _, s := range test.excludes {
+				if strings.Contains(string(body), s) {
+					t.Errorf("expecting\n%v not be contained in\n%s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestLandingPageGoTool(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, addr+"/vanity?go-get=1", nil)
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.LandingPage(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Result().Body)
+	expected := `<meta name="go-import" content="kkn.fi/vanity git https://github.com/kare/vanity">`
+	if !strings.Contains(string(body), expected) {
+		t.Errorf("expecting\n%v be contained in\n%s", expected, body)
+	}
+	if strings.Contains(string(body), "<h1>") {
+		t.Errorf("expecting go tool response instead of landing page, but got\n%s", body)
+	}
+}
@@ -0,0 +1,255 @@
+package vanity
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Module maps an import path under the vanity domain to a source code
+// repository. Paths that don't match any configured module are mapped to the
+// VCS URL by their first path component.
+type Module struct {
+	// Path is the module path relative to the domain, such as "/vanity" or
+	// "/cmd/tcpproxy".
+	Path string `json:"path"`
+	// VCS overrides the handler's version control system type.
+	VCS string `json:"vcs,omitempty"`
+	// RepoURL is the repository URL of the module. Defaults to the VCS URL
+	// joined with the repository name deduced from Path.
+	RepoURL string `json:"repoURL,omitempty"`
+	// DocsURL overrides the handler's documentation URL template. See
+	// DocsURL() for the template syntax.
+	DocsURL string `json:"docsURL,omitempty"`
+	// Forge overrides the forge deduced from the repository host.
+	Forge Forge `json:"forge,omitempty"`
+	// Branch overrides the handler's default branch in tree view URLs.
+	Branch string `json:"branch,omitempty"`
+	// Description is a short description of the module shown on HTML pages.
+	Description string `json:"description,omitempty"`
+	// License is the license of the module, such as BSD-3-Clause.
+	License string `json:"license,omitempty"`
+	// SourceDir is a local checkout of the module used for rendering
+	// documentation. See LocalDocs().
+	SourceDir string `json:"sourceDir,omitempty"`
+	// RepoDir is a local, typically bare, git repository of the module used
+	// for reading versions from tags.
+	RepoDir string `json:"repoDir,omitempty"`
+	// Subdir is the directory of the module within the repository. Tags of
+	// the module are prefixed with the directory, such as sub/v1.2.0. Path
+	// must end with the directory, because the go tool finds the module in
+	// the directory of the repository root's import path.
+	Subdir string `json:"subdir,omitempty"`
+	// State of the module. State defaults to StateActive.
+	State ModuleState `json:"state,omitempty"`
+	// GoSource overrides the home, directory and file URLs of the go-source
+	// meta tag, such as "https://github.com/kare/x
+	// https://github.com/kare/x/tree/master{/dir}
+	// https://github.com/kare/x/blob/master{/dir}/{file}#L{line}".
+	GoSource string `json:"goSource,omitempty"`
+	// Private module is served, but never listed in robots.txt, the
+	// sitemap, the index page, the API, the site-wide feed or search
+	// results, and its HTML pages ask crawlers not to index them.
+	Private bool `json:"private,omitempty"`
+}
+
+// ModuleState is the lifecycle state of a module.
+type ModuleState string
+
+// Module states.
+const (
+	// StateActive module is maintained.
+	StateActive ModuleState = "active"
+	// StateDeprecated module is no longer maintained, but still served.
+	StateDeprecated ModuleState = "deprecated"
+)
+
+func (s ModuleState) valid() bool {
+	return s == StateActive || s == StateDeprecated
+}
+
+// target describes a request path resolved to a module.
+type target struct {
+	domain string
+	// path is the request path without the version, such as
+	// /pkgabc/sub/foo.
+	path string
+	// importPath is the full import path of the request, such as
+	// kkn.fi/pkgabc/sub/foo.
+	importPath string
+	// moduleRoot is the import path of the module, such as kkn.fi/pkgabc.
+	moduleRoot string
+	// subPath is the package directory relative to the module root, such as
+	// sub/foo.
+	subPath  string
+	vcs      string
+	repoURL  string
+	repoName string
+	version  string
+	docsURL  string
+	forge    Forge
+	branch   string
+	module   *Module
+	// scheme is the scheme of the request the target is resolved for, such
+	// as http. It is empty if the target isn't resolved for a request.
+	scheme string
+}
+
+// cleanModulePath returns path with a leading slash and without a trailing
+// slash.
+func cleanModulePath(path string) string {
+	return "/" + strings.Trim(path, "/")
+}
+
+// splitRepoName deduces the repository name from a request path. Paths
+// prefixed with /cmd/ are stripped of the prefix. The returned root is the
+// path up to and including the repository name and sub is the rest of the
+// path.
+func splitRepoName(path string) (name, root, sub string) {
+	const cmd = "/cmd/"
+	prefix := "/"
+	if strings.HasPrefix(path, cmd) {
+		prefix = cmd
+	}
+	components := pathComponents(path[len(prefix)-1:])
+	if len(components) == 0 {
+		return "", "", ""
+	}
+	name = components[0]
+	return name, prefix + name, strings.Join(components[1:], "/")
+}
+
+// splitVersion separates a version query such as "@v1.2.3" from a request
+// path. The version may appear on any path component, like on pkg.go.dev.
+func splitVersion(path string) (string, string) {
+	i := strings.IndexByte(path, '@')
+	if i < 0 {
+		return path, ""
+	}
+	version := path[i+1:]
+	rest := ""
+	if j := strings.IndexByte(version, '/'); j >= 0 {
+		version, rest = version[:j], version[j:]
+	}
+	return path[:i] + rest, version
+}
+
+// publicModules returns the configured modules that aren't private. Private
+// modules are left out of listings: the index page, the API, the site-wide
+// feed, search results and the sitemap.
+func (h *handler) publicModules() []Module {
+	modules := make([]Module, 0, len(h.modules))
+	for _, m := range h.modules {
+		if !m.Private {
+			modules = append(modules, m)
+		}
+	}
+	return modules
+}
+
+// findModule returns the configured module with the longest path matching
+// the given path, or nil.
+func (h *handler) findModule(path string) *Module {
+	var found *Module
+	for i := range h.modules {
+		m := &h.modules[i]
+		if path != m.Path && !strings.HasPrefix(path, m.Path+"/") {
+			continue
+		}
+		if found == nil || len(m.Path) > len(found.Path) {
+			found = m
+		}
+	}
+	return found
+}
+
+// resolve maps a request path to a module.
+func (h *handler) resolve(domain, path string) *target {
+	path, version := splitVersion(path)
+	path = strings.TrimSuffix(path, "/")
+	t := &target{
+		domain:     domain,
+		path:       path,
+		importPath: domain + path,
+		vcs:        h.vcs,
+		version:    version,
+		docsURL:    h.docsURL,
+		forge:      h.forge,
+		branch:     h.branch,
+	}
+	if m := h.findModule(path); m != nil {
+		t.module = m
+		t.moduleRoot = domain + m.Path
+		t.subPath = strings.TrimPrefix(path[len(m.Path):], "/")
+		t.repoName, _, _ = splitRepoName(m.Path)
+		t.repoURL = h.vcsURL + t.repoName
+		if m.RepoURL != "" {
+			t.repoURL = m.RepoURL
+		}
+		if m.VCS != "" {
+			t.vcs = m.VCS
+		}
+		if m.DocsURL != "" {
+			t.docsURL = m.DocsURL
+		}
+		if m.Branch != "" {
+			t.branch = m.Branch
+		}
+		if h.docsTemplate != nil && m.SourceDir != "" {
+			t.docsURL = localDocsURL
+		}
+		return t
+	}
+	var root string
+	t.repoName, root, t.subPath = splitRepoName(path)
+	t.repoURL = h.vcsURL + t.repoName
+	t.moduleRoot = domain + root
+	return t
+}
+
+// resolveRequest is like resolve, but documentation URLs rendered by the
+// handler use the scheme of request r.
+func (h *handler) resolveRequest(r *http.Request, domain, path string) *target {
+	t := h.resolve(domain, path)
+	t.scheme = requestScheme(r)
+	return t
+}
+
+// requestScheme returns the scheme the client used for request r. The
+// X-Forwarded-Proto header of a reverse proxy takes precedence.
+func requestScheme(r *http.Request) string {
+	switch proto := r.Header.Get("X-Forwarded-Proto"); proto {
+	case "http", "https":
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	if r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+	return "http"
+}
+
+// importPrefix returns the import path prefix of the go-import meta tag.
+// Configured modules use the import path of the repository root, which is
+// the module root without the subdirectory of the module. Other paths
+// advertise the whole request path.
+func (t *target) importPrefix() string {
+	if t.module != nil {
+		if t.module.Subdir != "" {
+			return strings.TrimSuffix(t.moduleRoot, "/"+t.module.Subdir)
+		}
+		return t.moduleRoot
+	}
+	return strings.TrimSuffix(t.importPath, "/")
+}
+
+// Modules configures import paths that are mapped to specific repositories
+// instead of the VCS URL. Modules of repeated options are added together.
+func Modules(modules ...Module) Option {
+	return func(h http.Handler) error {
+		v := h.(*handler)
+		v.config.Modules = append(v.config.Modules, modules...)
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+package vanity
+
+import "strings"
+
+// Preview is the link-preview metadata of an HTML page. It is rendered as a
+// canonical link, and OpenGraph and Twitter card meta tags by the built-in
+// templates.
+type Preview struct {
+	// URL is the canonical URL of the page.
+	URL         string
+	SiteName    string
+	Title       string
+	Description string
+	// NoIndex asks crawlers not to index the page.
+	NoIndex bool
+}
+
+// Preview returns the link-preview metadata of a module or package page.
+// Description defaults to a generic description of the import path.
+func (p *ModulePage) Preview() Preview {
+	description := p.Description
+	if description == "" {
+		if p.ImportPath == p.ModuleRoot {
+			description = "Go module " + p.ImportPath + "."
+		} else {
+			description = "Go package " + p.ImportPath + " of module " + p.ModuleRoot + "."
+		}
+	}
+	return Preview{
+		URL:         "https://" + p.ImportPath,
+		SiteName:    siteName(p.ImportPath),
+		Title:       p.ImportPath,
+		Description: description,
+		NoIndex:     p.Private,
+	}
+}
+
+// Preview returns the link-preview metadata of a package documentation page.
+// Description defaults to the package synopsis.
+func (p *DocsPage) Preview() Preview {
+	preview := p.ModulePage.Preview()
+	if p.Synopsis != "" && (p.Description == "" || p.ImportPath != p.ModuleRoot) {
+		preview.Description = p.Synopsis
+	}
+	return preview
+}
+
+// Preview returns the link-preview metadata of the index page.
+func (p *IndexPage) Preview() Preview {
+	return Preview{
+		URL:         "https://" + p.Domain + "/",
+		SiteName:    p.Domain,
+		Title:       p.Domain,
+		Description: "Go modules hosted at " + p.Domain + ".",
+	}
+}
+
+// siteName returns the domain of importPath.
+func siteName(importPath string) string {
+	domain, _, _ := strings.Cut(importPath, "/")
+	return domain
+}
@@ -0,0 +1,98 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestPreview(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		url         string
+		title       string
+		description string
+	}{
+		{
+			name:        "go tool response opened in browser",
+			path:        "/other/sub?go-get=1",
+			url:         "https://kkn.fi/other/sub",
+			title:       "kkn.fi/other/sub",
+			description: "Go package kkn.fi/other/sub of module kkn.fi/other.",
+		},
+		{
+			name:        "landing page with description",
+			path:        "/project",
+			url:         "https://kkn.fi/project",
+			title:       "kkn.fi/project",
+			description: "Project &#34;does&#34; things &amp; stuff.",
+		},
+		{
+			name:        "landing page without description",
+			path:        "/cmd/tcpproxy",
+			url:         "https://kkn.fi/cmd/tcpproxy",
+			title:       "kkn.fi/cmd/tcpproxy",
+			description: "Go module kkn.fi/cmd/tcpproxy.",
+		},
+		{
+			name:        "documentation page",
+			path:        "/greet/sub",
+			url:         "https://kkn.fi/greet/sub",
+			title:       "kkn.fi/greet/sub",
+			description: "Package sub is a sub-package of greet.",
+		},
+		{
+			name:        "index page",
+			path:        "/",
+			url:         "https://kkn.fi/",
+			title:       "kkn.fi",
+			description: "Go modules hosted at kkn.fi.",
+		},
+	}
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(
+			vanity.Module{Path: "/project", Description: `Project "does" things & stuff.`},
+			vanity.Module{Path: "/greet", SourceDir: "testdata/docs/greet"},
+		),
+		vanity.LandingPage(nil),
+		vanity.LocalDocs(nil),
+		vanity.Log(log.New(io.Discard, "", 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv.ServeHTTP(rec, req)
+			body, _ := io.ReadAll(rec.Result().Body)
+			expected := []string{
+				`<link rel="canonical" href="` + test.url + `">`,
+				`<meta name="description" content="` + test.description + `">`,
+				`<meta property="og:site_name" content="kkn.fi">`,
+				`<meta property="og:title" content="` + test.title + `">`,
+				`<meta property="og:description" content="` + test.description + `">`,
+				`<meta property="og:url" content="` + test.url + `">`,
+				`<meta name="twitter:card" content="summary">`,
+				`<meta name="twitter:title" content="` + test.title + `">`,
+				`<meta name="twitter:description" content="` + test.description + `">`,
+			}
+			for _, s := range expected {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("expecting\n%v be contained in\n%s", s, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package vanity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader is a handler configured by a JSON configuration file. The
+// configuration is reloaded on Reload() or when Watch() notices a change of
+// the file. A new handler is built and validated before it atomically
+// replaces the old one, which keeps serving if the new configuration is
+// invalid. See ConfigFile.
+type Reloader struct {
+	name     string
+	override func(*ConfigFile) error
+	opts     []Option
+	current  atomic.Pointer[handler]
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	// data is the contents of the configuration file of the current
+	// handler.
+	data    []byte
+	history *History
+}
+
+// NewReloader returns a Reloader of the JSON configuration file name. An
+// override function, if not nil, is called with every configuration read
+// from the file before the handler is built, such as to apply command line
+// flags. Given options are applied after the options of the file. The
+// initial configuration must be valid.
+func NewReloader(name string, override func(*ConfigFile) error, opts ...Option) (*Reloader, error) {
+	r := &Reloader{
+		name:     name,
+		override: override,
+		opts:     opts,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.current.Load().ServeHTTP(w, req)
+}
+
+// Reload reads the configuration file and replaces the handler. The current
+// handler is kept if the configuration is invalid.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, err := os.Stat(r.name)
+	if err != nil {
+		return fmt.Errorf("vanity: error reading config file: %w", err)
+	}
+	// The file is read once per change, also when the configuration is
+	// invalid.
+	r.modTime, r.size = info.ModTime(), info.Size()
+	b, err := os.ReadFile(r.name)
+	if err != nil {
+		return fmt.Errorf("vanity: error reading config file: %w", err)
+	}
+	h, err := r.buildData(b)
+	if err != nil {
+		return err
+	}
+	r.accept(h, b, Revision{Source: SourceReload})
+	return nil
+}
+
+// buildData returns a handler of the contents b of the configuration file.
+func (r *Reloader) buildData(b []byte) (*handler, error) {
+	c, err := parseConfigFile(r.name, b)
+	if err != nil {
+		return nil, err
+	}
+	c.resolvePaths(filepath.Dir(r.name))
+	return r.build(c)
+}
+
+// accept replaces the handler with h of configuration file contents b and
+// records the revision rev of b in the history.
+func (r *Reloader) accept(h *handler, b []byte, rev Revision) {
+	r.current.Store(h)
+	r.data = b
+	if r.history == nil {
+		return
+	}
+	rev.Config = string(b)
+	if err := r.history.add(rev); err != nil {
+		h.log.Printf("%v", err)
+	}
+}
+
+// SetHistory records the accepted configurations of r as revisions of h.
+// The current configuration is recorded unless it is the latest revision.
+func (r *Reloader) SetHistory(h *History) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.history = h
+	r.accept(r.current.Load(), r.data, Revision{Source: SourceReload})
+}
+
+// History returns the history of r, or nil. See SetHistory().
+func (r *Reloader) History() *History {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.history
+}
+
+// Rollback restores the configuration of revision id of the history. The
+// configuration file is replaced atomically, and only if the configuration
+// of the revision is valid. The rollback is recorded as a new revision by
+// author.
+func (r *Reloader) Rollback(id int, author string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.history == nil {
+		return errors.New("vanity: no configuration history")
+	}
+	rev, err := r.history.Revision(id)
+	if err != nil {
+		return err
+	}
+	b := []byte(rev.Config)
+	h, err := r.buildData(b)
+	if err != nil {
+		return err
+	}
+	if err := r.write(b); err != nil {
+		return err
+	}
+	r.accept(h, b, Revision{Author: author, Source: SourceRollback, Rollback: id})
+	return nil
+}
+
+// write replaces the configuration file with b.
+func (r *Reloader) write(b []byte) error {
+	if err := writeFileAtomic(r.name, b); err != nil {
+		return err
+	}
+	info, err := os.Stat(r.name)
+	if err != nil {
+		return fmt.Errorf("vanity: error reading config file: %w", err)
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// build returns a handler of configuration c read from the configuration
+// file. The override function is applied to c.
+func (r *Reloader) build(c *ConfigFile) (*handler, error) {
+	if r.override != nil {
+		if err := r.override(c); err != nil {
+			return nil, err
+		}
+	}
+	return c.handler(r.opts...)
+}
+
+// changed reports whether the configuration file has changed since it was
+// last read.
+func (r *Reloader) changed() (bool, error) {
+	info, err := os.Stat(r.name)
+	if err != nil {
+		return false, fmt.Errorf("vanity: error reading config file: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !info.ModTime().Equal(r.modTime) || info.Size() != r.size, nil
+}
+
+// reloadChanged reloads the configuration file if it has changed and
+// reports whether it was reloaded.
+func (r *Reloader) reloadChanged() (bool, error) {
+	changed, err := r.changed()
+	if err != nil || !changed {
+		return false, err
+	}
+	if err := r.Reload(); err != nil {
+		return false, fmt.Errorf("vanity: keeping previous configuration: %w", err)
+	}
+	return true, nil
+}
+
+// logger returns the logger of the current handler.
+func (r *Reloader) logger() Logger {
+	return r.current.Load().log
+}
+
+// Watch polls the configuration file every interval and reloads it when its
+// modification time or size changes until ctx is done. Errors are logged
+// with the logger of the current handler.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := r.reloadChanged()
+		switch {
+		case err != nil:
+			r.logger().Printf("%v", err)
+		case reloaded:
+			r.logger().Printf("vanity: reloaded config file %v", r.name)
+		}
+	}
+}
+
+// update applies the change of author to the configuration file and
+// replaces the handler. The file is written atomically, and only if the
+// changed configuration is valid. Configuration files in the govanityurls
+// format can't be updated.
+func (r *Reloader) update(author string, change func(*ConfigFile) error) error {
+	if isGovanityurls(r.name) {
+		return fmt.Errorf("vanity: config file %v in govanityurls format can't be updated", r.name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := os.ReadFile(r.name)
+	if err != nil {
+		return fmt.Errorf("vanity: error reading config file: %w", err)
+	}
+	c, err := parseConfigFile(r.name, b)
+	if err != nil {
+		return err
+	}
+	if err := change(c); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(c); err != nil {
+		return fmt.Errorf("vanity: error encoding config file: %w", err)
+	}
+	// The handler is built of the encoded configuration, because resolving
+	// the paths and the override function modify the configuration.
+	h, err := r.buildData(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := r.write(buf.Bytes()); err != nil {
+		return err
+	}
+	r.accept(h, buf.Bytes(), Revision{Author: author, Source: SourceAdmin})
+	return nil
+}
+
+// writeFileAtomic replaces the file name with data by renaming a temporary
+// file in the same directory. The file keeps its permissions.
+func writeFileAtomic(name string, data []byte) (err error) {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("vanity: error writing config file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("vanity: error writing config file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("vanity: error writing config file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("vanity: error writing config file: %w", err)
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return fmt.Errorf("vanity: error writing config file: %w", err)
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("vanity: error writing config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package vanity_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"kkn.fi/vanity"
+)
+
+func TestReloaderIntegration(t *testing.T) {
+	integrationTest(t)
+	dir := t.TempDir()
+	name := writeConfigFile(t, dir, `{"modules": [{"path": "/x", "repoURL": "https://github.com/kare/x"}]}`)
+	r, err := vanity.NewReloader(name, nil, vanity.Log(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		before = `<meta name="go-import" content="kkn.fi/x git https://github.com/kare/x">`
+		after  = `<meta name="go-import" content="kkn.fi/x git https://gitlab.com/kare/x">`
+	)
+	if got := goImport(t, r, "/x"); got != before {
+		t.Fatalf("expected %v, but got %v", before, got)
+	}
+
+	// Invalid configuration keeps the current handler.
+	writeConfigFile(t, dir, `{"modules": [{"path": ""}]}`)
+	if err := r.Reload(); err == nil {
+		t.Error("expected reload of invalid configuration to fail")
+	}
+	if got := goImport(t, r, "/x"); got != before {
+		t.Errorf("expected %v after invalid configuration, but got %v", before, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+	writeConfigFile(t, dir, `{"modules": [{"path": "/x", "repoURL": "https://gitlab.com/kare/x"}], "vcs": "git"}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for goImport(t, r, "/x") != after {
+		if time.Now().After(deadline) {
+			t.Fatal("expected configuration change to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloaderOverrideIntegration(t *testing.T) {
+	integrationTest(t)
+	name := writeConfigFile(t, t.TempDir(), `{"vcsURL": "https://github.com/kare"}`)
+	override := func(c *vanity.ConfigFile) error {
+		c.VCSURL = "https://gitlab.com/kare"
+		return nil
+	}
+	r, err := vanity.NewReloader(name, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `<meta name="go-import" content="kkn.fi/x git https://gitlab.com/kare/x">`
+	if got := goImport(t, r, "/x"); got != want {
+		t.Errorf("expected %v, but got %v", want, got)
+	}
+	if _, err := vanity.NewReloader(name+".missing", nil); err == nil {
+		t.Error("expected an error of a missing config file")
+	}
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("expected reload of a removed config file to fail")
+	}
+}
@@ -0,0 +1,214 @@
+package vanity
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RuleFormat is a format of redirect rules generated from the module
+// mapping of a handler.
+type RuleFormat string
+
+// Supported redirect rule formats.
+const (
+	// RulesCaddy is a Caddyfile site block.
+	RulesCaddy RuleFormat = "caddy"
+	// RulesNginx is an nginx server block.
+	RulesNginx RuleFormat = "nginx"
+	// RulesRedirects is a _redirects file of Netlify and Cloudflare Pages.
+	RulesRedirects RuleFormat = "redirects"
+)
+
+// FileName returns the conventional file name of the rules of f.
+func (f RuleFormat) FileName() string {
+	switch f {
+	case RulesCaddy:
+		return "Caddyfile"
+	case RulesNginx:
+		return "nginx.conf"
+	case RulesRedirects:
+		return "_redirects"
+	}
+	return ""
+}
+
+func (f RuleFormat) valid() bool {
+	return f.FileName() != ""
+}
+
+// splat is the placeholder of the rest of a request path below a module
+// root in redirect rules.
+const splat = "{splat}"
+
+// ruleGoGetTemplate is the go tool response embedded in redirect rules. It
+// is executed with a *ModulePage. The response is kept on a single line
+// containing only the meta tags read by the go tool and documentation
+// tools.
+var ruleGoGetTemplate = template.Must(template.New("rule").Parse(
+	`<!doctype html><meta name="go-import" content="{{.ImportPrefix}} {{.VCS}} {{.RepoURL}}">` +
+		`{{with .GoSource}}<meta name="go-source" content="{{.}}">{{end}}`,
+))
+
+// moduleRule contains the responses of a module for redirect rules.
+type moduleRule struct {
+	id   int
+	path string
+	// goGet is the go tool response of the module.
+	goGet string
+	// docsURL and subDocsURL are the documentation URLs of the module root
+	// and of the package at splat below it. The URLs are empty if the
+	// handler renders the pages of the module.
+	docsURL    string
+	subDocsURL string
+}
+
+// moduleRules returns the rules of the configured modules ordered so that
+// the first matching rule is the rule of the longest module path.
+func (h *handler) moduleRules() ([]moduleRule, error) {
+	modules := make([]Module, len(h.modules))
+	copy(modules, h.modules)
+	sort.SliceStable(modules, func(i, j int) bool {
+		if len(modules[i].Path) != len(modules[j].Path) {
+			return len(modules[i].Path) > len(modules[j].Path)
+		}
+		return modules[i].Path < modules[j].Path
+	})
+	rules := make([]moduleRule, 0, len(modules))
+	for i, m := range modules {
+		var goGet strings.Builder
+		if err := ruleGoGetTemplate.Execute(&goGet, h.resolve(h.domain, m.Path).page()); err != nil {
+			return nil, fmt.Errorf("vanity: error generating rules of %v: %w", m.Path, err)
+		}
+		rule := moduleRule{id: i, path: m.Path, goGet: goGet.String()}
+		if h.landingPage == nil && (h.docsTemplate == nil || m.SourceDir == "") {
+			rule.docsURL = h.resolve(h.domain, m.Path).browserURL()
+			rule.subDocsURL = h.resolve(h.domain, m.Path+"/"+splat).browserURL()
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// RedirectRules returns redirect rules of handler h in format f for web
+// servers and hosting platforms that can't run the handler. The rules
+// answer the go tool with the meta tags of ServeHTTP and redirect
+// browsers to the documentation of the configured modules. Import paths
+// without a configured module aren't covered. Pages rendered by the handler,
+// such as landing pages and local documentation, aren't redirected. The
+// Caddy and nginx rules serve them from the files of a site written by
+// Export in the root of the server.
+//
+// Rules in the _redirects format rewrite all paths below a module root to
+// the page of the module written by Export, because Cloudflare Pages can't
+// match query parameters. Browsers are refreshed to the documentation of the
+// module root. Domain() must be set. The handler must be created with
+// NewHandlerWithOptions.
+func RedirectRules(h http.Handler, f RuleFormat) ([]byte, error) {
+	v, ok := h.(*handler)
+	if !ok {
+		return nil, errors.New("vanity: redirect rules require a handler created with NewHandlerWithOptions")
+	}
+	if v.domain == "" {
+		return nil, errors.New("vanity: redirect rules require a domain")
+	}
+	if !f.valid() {
+		return nil, fmt.Errorf("vanity: unknown redirect rule format %q", f)
+	}
+	rules, err := v.moduleRules()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch f {
+	case RulesCaddy:
+		err = caddyRules(&buf, v.domain, rules)
+	case RulesNginx:
+		err = nginxRules(&buf, v.domain, rules)
+	case RulesRedirects:
+		redirectsRules(&buf, rules)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// caddyRules writes a Caddyfile site block. Directives are wrapped in a
+// route block, so that they are evaluated in order.
+func caddyRules(buf *bytes.Buffer, domain string, rules []moduleRule) error {
+	fmt.Fprintf(buf, "%s {\n\troute {\n", domain)
+	for _, r := range rules {
+		if strings.Contains(r.goGet, "`") {
+			return fmt.Errorf("vanity: go tool response of %v can't be quoted in a Caddyfile", r.path)
+		}
+		fmt.Fprintf(buf, "\t\t@goget%d {\n", r.id)
+		fmt.Fprintf(buf, "\t\t\tpath %s %s/*\n", r.path, r.path)
+		fmt.Fprintf(buf, "\t\t\tquery go-get=1\n")
+		fmt.Fprintf(buf, "\t\t}\n")
+		fmt.Fprintf(buf, "\t\theader @goget%d Content-Type \"text/html; charset=utf-8\"\n", r.id)
+		// Braces of go-source are escaped, so that Caddy doesn't replace
+		// them as placeholders.
+		body := strings.ReplaceAll(r.goGet, "{", `\{`)
+		fmt.Fprintf(buf, "\t\trespond @goget%d `%s` 200\n", r.id, body)
+		if r.docsURL == "" {
+			// Pages rendered by the handler are served from the exported
+			// site.
+			fmt.Fprintf(buf, "\t\t@page%d path %s %s/*\n", r.id, r.path, r.path)
+			fmt.Fprintf(buf, "\t\troute @page%d {\n", r.id)
+			buf.WriteString("\t\t\ttry_files {path} {path}/index.html\n")
+			buf.WriteString("\t\t\tfile_server\n")
+			buf.WriteString("\t\t}\n")
+			continue
+		}
+		fmt.Fprintf(buf, "\t\tredir %s %s 302\n", r.path, r.docsURL)
+		fmt.Fprintf(buf, "\t\t@sub%d path_regexp sub%d ^%s/(.*)$\n", r.id, r.id, regexp.QuoteMeta(r.path))
+		fmt.Fprintf(buf, "\t\tredir @sub%d %s 302\n", r.id, strings.ReplaceAll(r.subDocsURL, splat, fmt.Sprintf("{re.sub%d.1}", r.id)))
+	}
+	buf.WriteString("\t}\n}\n")
+	return nil
+}
+
+// nginxRules writes an nginx server block.
+func nginxRules(buf *bytes.Buffer, domain string, rules []moduleRule) error {
+	const goGet = `if ($args ~ "(^|&)go-get=1(&|$)") {`
+	fmt.Fprintf(buf, "server {\n\tserver_name %s;\n", domain)
+	for _, r := range rules {
+		if strings.Contains(r.goGet, "$") {
+			return fmt.Errorf("vanity: go tool response of %v can't be quoted in nginx configuration", r.path)
+		}
+		body := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(r.goGet)
+		for _, loc := range []struct {
+			location string
+			docsURL  string
+		}{
+			{"= " + r.path, r.docsURL},
+			{"~ ^" + regexp.QuoteMeta(r.path) + "/(.*)$", strings.ReplaceAll(r.subDocsURL, splat, "$1")},
+		} {
+			fmt.Fprintf(buf, "\n\tlocation %s {\n", loc.location)
+			fmt.Fprintf(buf, "\t\tdefault_type \"text/html; charset=utf-8\";\n")
+			fmt.Fprintf(buf, "\t\t%s\n\t\t\treturn 200 '%s';\n\t\t}\n", goGet, body)
+			if loc.docsURL != "" {
+				fmt.Fprintf(buf, "\t\treturn 302 %s;\n", loc.docsURL)
+			} else {
+				buf.WriteString("\t\ttry_files $uri $uri/index.html =404;\n")
+			}
+			buf.WriteString("\t}\n")
+		}
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+// redirectsRules writes a _redirects file rewriting the paths below module
+// roots to the exported module pages.
+func redirectsRules(buf *bytes.Buffer, rules []moduleRule) {
+	for _, r := range rules {
+		fmt.Fprintf(buf, "%s/* %s/index.html 200\n", r.path, r.path)
+	}
+}
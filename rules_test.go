@@ -0,0 +1,115 @@
+package vanity_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRedirectRules(t *testing.T) {
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.Domain("kkn.fi"),
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.LocalDocs(nil),
+		vanity.Modules(
+			vanity.Module{Path: "/vanity"},
+			vanity.Module{Path: "/cmd/tcpproxy"},
+			vanity.Module{Path: "/x", RepoURL: "https://gitlab.com/kare/x", DocsURL: vanity.DocsForgeTree},
+			vanity.Module{Path: "/greet", SourceDir: "testdata/docs/greet"},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format vanity.RuleFormat
+	}{
+		{format: vanity.RulesCaddy},
+		{format: vanity.RulesNginx},
+		{format: vanity.RulesRedirects},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(string(test.format), func(t *testing.T) {
+			t.Parallel()
+
+			got, err := vanity.RedirectRules(srv, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", "rules", test.format.FileName()+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("expected rules to match %v:\n%s\nbut got:\n%s", golden, want, got)
+			}
+		})
+	}
+}
+
+func TestRedirectRulesLandingPages(t *testing.T) {
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.Domain("kkn.fi"),
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.LandingPage(nil),
+		vanity.Modules(vanity.Module{Path: "/vanity"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format   vanity.RuleFormat
+		fallback string
+	}{
+		{
+			format:   vanity.RulesCaddy,
+			fallback: "\t\t@page0 path /vanity /vanity/*\n\t\troute @page0 {\n\t\t\ttry_files {path} {path}/index.html\n\t\t\tfile_server\n\t\t}\n",
+		},
+		{
+			format:   vanity.RulesNginx,
+			fallback: "\t\ttry_files $uri $uri/index.html =404;\n",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(string(test.format), func(t *testing.T) {
+			t.Parallel()
+
+			got, err := vanity.RedirectRules(srv, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(got), test.fallback) {
+				t.Errorf("expected rules to contain the fallback to the exported site\n%s\nbut got:\n%s", test.fallback, got)
+			}
+			if strings.Contains(string(got), "pkg.go.dev") {
+				t.Errorf("expected landing pages not to be redirected, but got:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestRedirectRulesInvalid(t *testing.T) {
+	srv, err := vanity.NewHandlerWithOptions(vanity.Domain("kkn.fi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = vanity.RedirectRules(srv, "apache")
+	const want = `vanity: unknown redirect rule format "apache"`
+	if err == nil || err.Error() != want {
+		t.Errorf("expected error %q, but got %v", want, err)
+	}
+}
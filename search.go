@@ -0,0 +1,223 @@
+package vanity
+
+import (
+	"go/build"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// searchPath is the path of the module search.
+const searchPath = "/search"
+
+// searchMaxResults is the maximum number of search results.
+const searchMaxResults = 100
+
+// Search term weights of the indexed fields.
+const (
+	weightPath     = 3
+	weightSynopsis = 1
+)
+
+// DefaultSearchTemplate is the built-in search page template. It is executed
+// with a *SearchPage.
+var DefaultSearchTemplate = templates.Lookup("search.html")
+
+// SearchPage describes the results of a search.
+type SearchPage struct {
+	Domain  string         `json:"domain"`
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+// SearchResult is a package matching a search query.
+type SearchResult struct {
+	// Path is the path of the package on the vanity server.
+	Path       string `json:"path"`
+	ImportPath string `json:"importPath"`
+	// Synopsis is the package synopsis or the module description.
+	Synopsis string `json:"synopsis,omitempty"`
+	Score    int    `json:"score"`
+}
+
+// searchIndex is an inverted index of the packages of the configured
+// modules.
+type searchIndex struct {
+	docs []SearchResult
+	// terms maps a term to the documents containing it.
+	terms map[string][]posting
+	// sorted contains the terms in order for prefix lookups.
+	sorted []string
+}
+
+type posting struct {
+	doc    int
+	weight int
+}
+
+// tokenize splits s into lower case terms.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// add indexes a document.
+func (idx *searchIndex) add(doc SearchResult) {
+	id := len(idx.docs)
+	idx.docs = append(idx.docs, doc)
+	weights := make(map[string]int)
+	for _, term := range tokenize(doc.Path) {
+		weights[term] += weightPath
+	}
+	for _, term := range tokenize(doc.Synopsis) {
+		weights[term] += weightSynopsis
+	}
+	for term, weight := range weights {
+		idx.terms[term] = append(idx.terms[term], posting{doc: id, weight: weight})
+	}
+}
+
+// search returns the documents matching all terms of query. The last term
+// of the query matches as a prefix. Import paths of the results are prefixed
+// with domain.
+func (idx *searchIndex) search(domain, query string) []SearchResult {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	scores := make(map[int]int)
+	for i, term := range terms {
+		matches := make(map[int]int)
+		for _, t := range idx.lookup(term, i == len(terms)-1) {
+			for _, p := range idx.terms[t] {
+				matches[p.doc] += p.weight
+			}
+		}
+		if i == 0 {
+			scores = matches
+			continue
+		}
+		for doc := range scores {
+			if w, ok := matches[doc]; ok {
+				scores[doc] += w
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+	results := make([]SearchResult, 0, len(scores))
+	for doc, score := range scores {
+		r := idx.docs[doc]
+		r.ImportPath = domain + r.Path
+		r.Score = score
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > searchMaxResults {
+		results = results[:searchMaxResults]
+	}
+	return results
+}
+
+// lookup returns the index terms equal to term, or prefixed with term.
+func (idx *searchIndex) lookup(term string, prefix bool) []string {
+	if !prefix {
+		return []string{term}
+	}
+	var terms []string
+	i := sort.SearchStrings(idx.sorted, term)
+	for ; i < len(idx.sorted) && strings.HasPrefix(idx.sorted[i], term); i++ {
+		terms = append(terms, idx.sorted[i])
+	}
+	return terms
+}
+
+// buildSearchIndex indexes the paths and descriptions of the configured
+// modules, and the packages of modules with local sources.
+func (h *handler) buildSearchIndex() *searchIndex {
+	idx := &searchIndex{terms: make(map[string][]posting)}
+	for _, m := range h.publicModules() {
+		root := SearchResult{
+			Path:     m.Path,
+			Synopsis: m.Description,
+		}
+		if m.SourceDir == "" {
+			idx.add(root)
+			continue
+		}
+		if bp, err := build.Default.ImportDir(m.SourceDir, build.ImportComment); err == nil {
+			if synopsis := packageSynopsis(m.SourceDir, bp.GoFiles); synopsis != "" && root.Synopsis == "" {
+				root.Synopsis = synopsis
+			}
+		}
+		idx.add(root)
+		pkgs, err := subPackages(m.SourceDir, m.Path, "")
+		if err != nil {
+			h.log.Printf("vanity: error indexing packages of %v: %v", m.Path, err)
+		}
+		for _, pkg := range pkgs {
+			idx.add(SearchResult{
+				Path:     pkg.Path,
+				Synopsis: pkg.Synopsis,
+			})
+		}
+	}
+	for term := range idx.terms {
+		idx.sorted = append(idx.sorted, term)
+	}
+	sort.Strings(idx.sorted)
+	return idx
+}
+
+// loadSearchIndex returns the search index of the handler. The index is built on
+// first use. A handler's configuration doesn't change, so configuration
+// changes always build a new index with the new handler.
+func (h *handler) loadSearchIndex() *searchIndex {
+	h.searchMu.Lock()
+	defer h.searchMu.Unlock()
+	if h.search == nil {
+		h.search = h.buildSearchIndex()
+	}
+	return h.search
+}
+
+// serveSearch responds with the packages matching the q query parameter as
+// HTML, or as JSON if the client prefers it or format=json is given.
+func (h *handler) serveSearch(w http.ResponseWriter, r *http.Request, domain string) {
+	query := strings.TrimSpace(r.FormValue("q"))
+	page := &SearchPage{
+		Domain:  domain,
+		Query:   query,
+		Results: h.loadSearchIndex().search(domain, query),
+	}
+	if page.Results == nil {
+		page.Results = []SearchResult{}
+	}
+	if r.FormValue("format") == "json" || acceptsJSON(r) {
+		h.writeJSON(w, http.StatusOK, page)
+		return
+	}
+	h.render(w, h.searchTemplate, page)
+}
+
+// SearchTemplate sets the template of the search page at /search. If tmpl is
+// nil, DefaultSearchTemplate is used. The template is executed with a
+// *SearchPage.
+func SearchTemplate(tmpl *template.Template) Option {
+	return func(h http.Handler) error {
+		v := h.(*handler)
+		if tmpl == nil {
+			tmpl = DefaultSearchTemplate
+		}
+		v.config.SearchTemplate = tmpl
+		return nil
+	}
+}
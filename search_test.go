@@ -0,0 +1,184 @@
+package vanity_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		accept   string
+		contains []string
+		excludes []string
+		body     string
+	}{
+		{
+			name:  "json orders equal scores by path",
+			query: "?q=proxy&format=json",
+			body: `{
+  "domain": "kkn.fi",
+  "query": "proxy",
+  "results": [
+    {
+      "path": "/cmd/tcpproxy",
+      "importPath": "kkn.fi/cmd/tcpproxy",
+      "synopsis": "TCP proxy server.",
+      "score": 1
+    },
+    {
+      "path": "/vanity",
+      "importPath": "kkn.fi/vanity",
+      "synopsis": "Vanity import path proxy for the go tool.",
+      "score": 1
+    }
+  ]
+}
+`,
+		},
+		{
+			name:  "path prefix matches rank first",
+			query: "?q=vanity+import&format=json",
+			body: `{
+  "domain": "kkn.fi",
+  "query": "vanity import",
+  "results": [
+    {
+      "path": "/vanity",
+      "importPath": "kkn.fi/vanity",
+      "synopsis": "Vanity import path proxy for the go tool.",
+      "score": 5
+    }
+  ]
+}
+`,
+		},
+		{
+			name:  "last term matches as prefix",
+			query: "?q=tcp&format=json",
+			body: `{
+  "domain": "kkn.fi",
+  "query": "tcp",
+  "results": [
+    {
+      "path": "/cmd/tcpproxy",
+      "importPath": "kkn.fi/cmd/tcpproxy",
+      "synopsis": "TCP proxy server.",
+      "score": 4
+    }
+  ]
+}
+`,
+		},
+		{
+			name:   "json with accept header",
+			query:  "?q=nothing+matches",
+			accept: "application/json",
+			body: `{
+  "domain": "kkn.fi",
+  "query": "nothing matches",
+  "results": []
+}
+`,
+		},
+		{
+			name:  "package synopsis from local sources",
+			query: "?q=nested+deep",
+			contains: []string{
+				`<dt><a href="/greet/sub/inner">kkn.fi/greet/sub/inner</a></dt>`,
+				`<dd>Package inner is nested deeper.</dd>`,
+			},
+			excludes: []string{
+				`/greet/sub"`,
+			},
+		},
+		{
+			name:  "all terms must match",
+			query: "?q=greet+sub",
+			contains: []string{
+				`<a href="/greet/sub">kkn.fi/greet/sub</a>`,
+				`<a href="/greet/sub/inner">kkn.fi/greet/sub/inner</a>`,
+			},
+			excludes: []string{
+				`<a href="/greet">`,
+			},
+		},
+		{
+			name:  "root package synopsis",
+			query: "?q=hello",
+			contains: []string{
+				`<a href="/greet">kkn.fi/greet</a>`,
+				`<dd>Package greet says hello.</dd>`,
+			},
+		},
+		{
+			name:  "no results",
+			query: "?q=%3Cmissing%3E",
+			contains: []string{
+				`<title>&lt;missing&gt; - Search kkn.fi</title>`,
+				`value="&lt;missing&gt;"`,
+				`<p>No packages found.</p>`,
+			},
+		},
+		{
+			name:  "empty query",
+			query: "",
+			contains: []string{
+				`<title>Search kkn.fi</title>`,
+			},
+			excludes: []string{
+				`No packages found.`,
+			},
+		},
+	}
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(
+			vanity.Module{Path: "/vanity", Description: "Vanity import path proxy for the go tool."},
+			vanity.Module{Path: "/cmd/tcpproxy", Description: "TCP proxy server."},
+			vanity.Module{Path: "/greet", SourceDir: "testdata/docs/greet"},
+		),
+		vanity.Log(log.New(io.Discard, "", 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+"/search"+test.query, nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("expected response status %v, but got %v", http.StatusOK, res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if test.body != "" && string(body) != test.body {
+				t.Errorf("expecting body\n%v, but got\n%s", test.body, body)
+			}
+			for _, s := range test.contains {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("expecting\n%v be contained in\n%s", s, body)
+				}
+			}
+			for _, s := range test.excludes {
+				if strings.Contains(string(body), s) {
+					t.Errorf("expecting\n%v not be contained in\n%s", s, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package vanity
+
+import (
+	"regexp"
+	"strings"
+)
+
+// semver is a parsed semantic version as used by Go modules, such as
+// v1.2.3-pre.1+incompatible.
+type semver struct {
+	major, minor, patch string
+	prerelease          string
+	build               string
+}
+
+// parseSemver parses a full semantic version with a v prefix. Shorthands
+// such as v1 or v1.2 are not accepted, because they are not valid tags of
+// Go modules.
+func parseSemver(v string) (semver, bool) {
+	var s semver
+	if !strings.HasPrefix(v, "v") {
+		return s, false
+	}
+	v = v[1:]
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		s.build = v[i+1:]
+		v = v[:i]
+		if !validIdentifiers(s.build, false) {
+			return s, false
+		}
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		s.prerelease = v[i+1:]
+		v = v[:i]
+		if !validIdentifiers(s.prerelease, true) {
+			return s, false
+		}
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return s, false
+	}
+	for _, p := range parts {
+		if !isNumber(p) {
+			return s, false
+		}
+	}
+	s.major, s.minor, s.patch = parts[0], parts[1], parts[2]
+	return s, true
+}
+
+// isNumber reports whether s is a decimal number without leading zeros.
+func isNumber(s string) bool {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validIdentifiers reports whether s is a dot separated list of prerelease or
+// build identifiers.
+func validIdentifiers(s string, prerelease bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, c := range id {
+			switch {
+			case c >= '0' && c <= '9':
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if prerelease && numeric && !isNumber(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareNumbers compares decimal numbers without leading zeros.
+func compareNumbers(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// comparePrerelease compares prerelease versions by semver precedence. A
+// version without prerelease has higher precedence.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, bn := isNumber(as[i]), isNumber(bs[i])
+		switch {
+		case an && bn:
+			return compareNumbers(as[i], bs[i])
+		case an:
+			return -1
+		case bn:
+			return 1
+		default:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// compareSemver returns an integer comparing versions a and b by semver
+// precedence. Invalid versions are considered lower than valid ones. Build
+// metadata is ignored.
+func compareSemver(a, b string) int {
+	as, aok := parseSemver(a)
+	bs, bok := parseSemver(b)
+	switch {
+	case !aok && !bok:
+		return 0
+	case !aok:
+		return -1
+	case !bok:
+		return 1
+	}
+	if c := compareNumbers(as.major, bs.major); c != 0 {
+		return c
+	}
+	if c := compareNumbers(as.minor, bs.minor); c != 0 {
+		return c
+	}
+	if c := compareNumbers(as.patch, bs.patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(as.prerelease, bs.prerelease)
+}
+
+// pseudoVersionRE matches Go pseudo-versions such as
+// v0.0.0-20191109021931-daa7c04131f5.
+var pseudoVersionRE = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// isPseudoVersion reports whether v is a Go pseudo-version.
+func isPseudoVersion(v string) bool {
+	return strings.Count(v, "-") >= 2 && pseudoVersionRE.MatchString(v)
+}
@@ -0,0 +1,139 @@
+package vanity
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Paths of the generated robots.txt and sitemap.
+const (
+	robotsTxtPath = "/robots.txt"
+	sitemapPath   = "/sitemap.xml"
+)
+
+// sitemapURLSet is a sitemap as specified by https://www.sitemaps.org.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URL     []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// page is a page served to browsers that crawlers may index.
+type page struct {
+	path    string
+	lastMod time.Time
+	// tree reports whether the pages under path are served too.
+	tree bool
+}
+
+// publicPages returns the pages of public modules served by the handler:
+// the index page, landing or documentation pages and release feeds. Modules
+// redirected to an external documentation URL have no pages of their own.
+func (h *handler) publicPages(domain string) []page {
+	pages := []page{{path: "/"}}
+	feed := false
+	for _, m := range h.publicModules() {
+		t := h.resolve(domain, m.Path)
+		var lastMod time.Time
+		if m.RepoDir != "" {
+			versions, err := h.versions(t)
+			if err != nil && !errors.Is(err, errNoRepoDir) {
+				h.log.Printf("%v", err)
+			}
+			for _, v := range versions {
+				if v.Time.After(lastMod) {
+					lastMod = v.Time
+				}
+			}
+			pages = append(pages, page{path: m.Path + feedPath, lastMod: lastMod})
+			feed = true
+		}
+		switch {
+		case h.docsTemplate != nil && m.SourceDir != "":
+			pages = append(pages, page{path: m.Path, lastMod: lastMod, tree: true})
+		case h.landingPage != nil:
+			pages = append(pages, page{path: m.Path, lastMod: lastMod})
+		}
+	}
+	if feed {
+		pages = append(pages, page{path: feedPath})
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].path < pages[j].path
+	})
+	return pages
+}
+
+// generateRobotsTxt returns a robots.txt allowing crawlers to index the public
+// pages and the static directory, and disallowing everything else. Private
+// modules are not mentioned.
+func (h *handler) generateRobotsTxt(domain string) string {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	for _, p := range h.publicPages(domain) {
+		b.WriteString("Allow: " + p.path + "$\n")
+		if p.tree {
+			b.WriteString("Allow: " + p.path + "/\n")
+		}
+	}
+	if h.static != nil {
+		b.WriteString("Allow: " + addSuffixSlash(cleanModulePath(h.static.uRLPath)) + "\n")
+	}
+	b.WriteString("Disallow: /\n")
+	b.WriteString("\nSitemap: https://" + domain + sitemapPath + "\n")
+	return b.String()
+}
+
+// serveRobotsTxt responds with the configured robots.txt, or a generated one.
+func (h *handler) serveRobotsTxt(w http.ResponseWriter, domain string) {
+	robotsTxt := h.robotsTxt
+	if robotsTxt == "" {
+		robotsTxt = h.generateRobotsTxt(domain)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(robotsTxt)); err != nil {
+		h.log.Printf("vanity: i/o error writing robots.txt response: %v", err)
+	}
+}
+
+// serveSitemap responds with a sitemap of the public pages. Sub-packages of
+// modules with local documentation are listed too.
+func (h *handler) serveSitemap(w http.ResponseWriter, domain string) {
+	var urls []sitemapURL
+	for _, p := range h.publicPages(domain) {
+		u := sitemapURL{Loc: "https://" + domain + p.path}
+		if !p.lastMod.IsZero() {
+			u.LastMod = p.lastMod.UTC().Format(time.RFC3339)
+		}
+		urls = append(urls, u)
+		if !p.tree {
+			continue
+		}
+		m := h.findModule(p.path)
+		pkgs, err := subPackages(m.SourceDir, m.Path, domain+m.Path)
+		if err != nil {
+			h.log.Printf("vanity: error listing packages of %v: %v", domain+m.Path, err)
+		}
+		for _, pkg := range pkgs {
+			urls = append(urls, sitemapURL{Loc: "https://" + pkg.ImportPath})
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		h.log.Printf("vanity: i/o error writing sitemap response: %v", err)
+		return
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(sitemapURLSet{URL: urls}); err != nil {
+		h.log.Printf("vanity: i/o error writing sitemap response: %v", err)
+	}
+}
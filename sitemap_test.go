@@ -0,0 +1,171 @@
+package vanity_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func sitemapHandler(t *testing.T) http.Handler {
+	t.Helper()
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.LandingPage(nil),
+		vanity.LocalDocs(nil),
+		vanity.StaticDir("testdata", "/.static/"),
+		vanity.Modules(
+			vanity.Module{Path: "/greet", SourceDir: "testdata/docs/greet"},
+			vanity.Module{Path: "/project"},
+			vanity.Module{Path: "/secret", Private: true},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestGeneratedRobotsTxt(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, addr+"/robots.txt", nil)
+	sitemapHandler(t).ServeHTTP(rec, req)
+	res := rec.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected response status 200, but got %v", res.StatusCode)
+	}
+	body, _ := io.ReadAll(res.Body)
+	expected := `User-agent: *
+Allow: /$
+Allow: /greet$
+Allow: /greet/
+Allow: /project$
+Allow: /.static/
+Disallow: /
+
+Sitemap: https://kkn.fi/sitemap.xml
+`
+	if string(body) != expected {
+		t.Errorf("expecting body to match:\n'%v', but got:\n'%s'", expected, body)
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, addr+"/sitemap.xml", nil)
+	sitemapHandler(t).ServeHTTP(rec, req)
+	res := rec.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected response status 200, but got %v", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+		t.Errorf("expected xml content type, but got %v", ct)
+	}
+	body, _ := io.ReadAll(res.Body)
+	for _, s := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+		`<loc>https://kkn.fi/</loc>`,
+		`<loc>https://kkn.fi/greet</loc>`,
+		`<loc>https://kkn.fi/greet/sub</loc>`,
+		`<loc>https://kkn.fi/greet/sub/inner</loc>`,
+		`<loc>https://kkn.fi/project</loc>`,
+	} {
+		if !strings.Contains(string(body), s) {
+			t.Errorf("expected sitemap to contain %q, but got:\n%s", s, body)
+		}
+	}
+	for _, s := range []string{"secret", "nested", "testdata"} {
+		if strings.Contains(string(body), s) {
+			t.Errorf("expected sitemap not to contain %q, but got:\n%s", s, body)
+		}
+	}
+}
+
+func TestPrivateModuleNoIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		noindex bool
+	}{
+		{
+			name:    "private",
+			path:    "/secret",
+			noindex: true,
+		},
+		{
+			name: "public",
+			path: "/project",
+		},
+	}
+	srv := sitemapHandler(t)
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv.ServeHTTP(rec, req)
+			body, _ := io.ReadAll(rec.Result().Body)
+			const meta = `<meta name="robots" content="noindex">`
+			if got := strings.Contains(string(body), meta); got != test.noindex {
+				t.Errorf("expected noindex %v, but got body:\n%s", test.noindex, body)
+			}
+		})
+	}
+}
+
+func TestPrivateModuleUnlisted(t *testing.T) {
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(
+			vanity.Module{Path: "/project", Description: "Project module."},
+			vanity.Module{Path: "/secret", Description: "Secret module."},
+			vanity.Module{Path: "/hidden", Description: "Hidden module.", Private: true},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+	}{
+		{
+			name: "index page",
+			path: "/",
+		},
+		{
+			name: "api",
+			path: "/api/modules",
+		},
+		{
+			name: "search",
+			path: "/search?q=module&format=json",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("expected response status 200, but got %v", res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if !strings.Contains(string(body), "/secret") {
+				t.Errorf("expected public module /secret to be listed, but got:\n%s", body)
+			}
+			if strings.Contains(string(body), "/hidden") {
+				t.Errorf("expected private module /hidden not to be listed, but got:\n%s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package vanity
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// templates contains the built-in HTML templates.
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// goGetTemplate is the response template for the go tool. It is executed with
+// a *ModulePage.
+var goGetTemplate = templates.Lookup("goget.html")
+
+// render executes tmpl with data and writes the result as an HTML response.
+func (h *handler) render(w http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		h.log.Printf("vanity: error executing template %v: %v", tmpl.Name(), err)
+		status := http.StatusInternalServerError
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		h.log.Printf("vanity: i/o error writing html response: %v", err)
+	}
+}
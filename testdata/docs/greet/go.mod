@@ -0,0 +1,3 @@
+module kkn.fi/greet
+
+go 1.24
@@ -0,0 +1,32 @@
+// Package greet says hello. It exists for testing documentation rendering.
+package greet
+
+import "fmt"
+
+// Greeting is the default greeting.
+const Greeting = "Hello"
+
+// Loud makes greetings loud.
+var Loud = false
+
+// Hello returns a greeting for name.
+func Hello(name string) string {
+	return fmt.Sprintf("%v, %v!", Greeting, name)
+}
+
+// Greeter greets people.
+type Greeter struct {
+	// Greeting used by the greeter.
+	Greeting string
+	secret   string
+}
+
+// NewGreeter returns a Greeter with the given greeting.
+func NewGreeter(greeting string) *Greeter {
+	return &Greeter{Greeting: greeting}
+}
+
+// Greet returns a greeting for name.
+func (g *Greeter) Greet(name string) string {
+	return fmt.Sprintf("%v, %v!", g.Greeting, name)
+}
@@ -0,0 +1,12 @@
+package greet_test
+
+import (
+	"fmt"
+
+	"kkn.fi/greet"
+)
+
+func ExampleHello() {
+	fmt.Println(greet.Hello("Gopher"))
+	// Output: Hello, Gopher!
+}
@@ -0,0 +1 @@
+module kkn.fi/greet/nested
@@ -0,0 +1,2 @@
+// Package nested is a nested module.
+package nested
@@ -0,0 +1,2 @@
+// Package inner is nested deeper.
+package inner
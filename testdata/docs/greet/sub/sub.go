@@ -0,0 +1,2 @@
+// Package sub is a sub-package of greet.
+package sub
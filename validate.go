@@ -0,0 +1,259 @@
+package vanity
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of a handler. The zero value of a field selects
+// the same default as the corresponding Option. See NewHandler().
+type Config struct {
+	// Domain is the hostname of the vanity server. See Domain().
+	Domain string
+	// VCS is the version control system type. Defaults to git. See VCS().
+	VCS string
+	// VCSURL is the URL of the repositories. It is required unless every
+	// module sets RepoURL. See VCSURL().
+	VCSURL string
+	// DocsURL is the documentation URL template. See DocsURL().
+	DocsURL string
+	// Forge is the default forge of repositories. See DefaultForge().
+	Forge Forge
+	// Branch is the default branch of repositories. See DefaultBranch().
+	Branch string
+	// Modules maps import paths to repositories. See Modules().
+	Modules []Module
+	// StaticDir is the local directory of static content and StaticURL its
+	// URL path, which defaults to /.static/. See StaticDir().
+	StaticDir string
+	StaticURL string
+	// IndexPageHandler serves the index page. See IndexPageHandler().
+	IndexPageHandler http.Handler
+	// IndexTemplate and IndexData render the index page. See IndexTemplate().
+	IndexTemplate *template.Template
+	IndexData     interface{}
+	// LandingPage renders module landing pages. See LandingPage().
+	LandingPage *template.Template
+	// DocsTemplate renders local documentation. See LocalDocs().
+	DocsTemplate *template.Template
+	// SearchTemplate renders search results. See SearchTemplate().
+	SearchTemplate *template.Template
+	// RobotsTxt is the content of robots.txt. Empty generates robots.txt
+	// from the modules. See RobotsTxt().
+	RobotsTxt string
+	// CacheMaxAge is the max age of the Cache-Control header. See
+	// CacheMaxAge().
+	CacheMaxAge time.Duration
+	// Log is the error logger. Defaults to standard error. See Log().
+	Log Logger
+}
+
+// validVCS reports whether vcs is a version control system known by the go
+// tool.
+func validVCS(vcs string) bool {
+	switch vcs {
+	case "bzr", "fossil", "git", "hg", "svn", "mod":
+		return true
+	}
+	return false
+}
+
+// docsURLPlaceholders are the placeholders of the documentation URL template.
+var docsURLPlaceholders = map[string]bool{
+	"{domain}":     true,
+	"{importPath}": true,
+	"{moduleRoot}": true,
+	"{subPath}":    true,
+	"{repoURL}":    true,
+	"{repo}":       true,
+	"{repoName}":   true,
+	"{version}":    true,
+	"{@version}":   true,
+	"{branch}":     true,
+	"{tree}":       true,
+}
+
+// validateDocsURL returns an error if template has an unknown placeholder.
+func validateDocsURL(template string) error {
+	rest := template
+	for {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			return nil
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return fmt.Errorf("docs URL %q has an unterminated placeholder", template)
+		}
+		if p := rest[i : i+j+1]; !docsURLPlaceholders[p] {
+			return fmt.Errorf("docs URL %q has unknown placeholder %s", template, p)
+		}
+		rest = rest[i+j+1:]
+	}
+}
+
+// validateURL returns an error if rawURL is not an absolute URL. File URLs
+// of local repositories have no host.
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "file" && u.Path != "" {
+		return nil
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute URL", rawURL)
+	}
+	return nil
+}
+
+// validateStaticDir returns an error if path is not a directory readable by
+// everyone.
+func validateStaticDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("vanity: static dir path stat error: %w", err)
+	}
+	if !info.IsDir() {
+		return errors.New("vanity: static dir path is not a directory")
+	}
+	const (
+		read = 4
+		// write = 2
+		// exec = 1
+	)
+	const (
+		readUser  = read << 6
+		readGroup = read << 3
+		readOther = read << 0
+	)
+	if info.Mode().Perm()&readOther == 0 {
+		return ErrNotReadable
+	}
+	if info.Mode().Perm()&readGroup == 0 {
+		return ErrNotReadable
+	}
+	if info.Mode().Perm()&readUser == 0 {
+		return ErrNotReadable
+	}
+	return nil
+}
+
+// validationError is returned by NewHandler() for an invalid configuration.
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *validationError) Unwrap() error {
+	return e.err
+}
+
+// Validate checks the configuration and returns all problems joined with
+// errors.Join(), or nil if the configuration is valid.
+func (c Config) Validate() error {
+	var errs []error
+	add := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf("vanity: "+format, a...))
+	}
+	if strings.Contains(c.Domain, "/") {
+		add("domain %q is not a hostname", c.Domain)
+	}
+	if c.VCS != "" && !validVCS(c.VCS) {
+		add("unknown VCS %q", c.VCS)
+	}
+	if c.VCSURL != "" {
+		if err := validateURL(c.VCSURL); err != nil {
+			add("malformed VCS URL: %w", err)
+		}
+	}
+	if err := validateDocsURL(c.DocsURL); err != nil {
+		add("%w", err)
+	}
+	if c.Forge != "" && !c.Forge.valid() {
+		add("unknown forge %q", c.Forge)
+	}
+	if c.IndexPageHandler != nil && c.IndexTemplate != nil {
+		add("both index page handler and index template are set")
+	}
+	if c.CacheMaxAge < 0 {
+		add("negative cache max age %v", c.CacheMaxAge)
+	}
+	switch {
+	case c.StaticDir != "":
+		if err := validateStaticDir(c.StaticDir); err != nil {
+			errs = append(errs, err)
+		}
+	case c.StaticURL != "":
+		add("static URL %q without static dir", c.StaticURL)
+	}
+	paths := make(map[string]bool)
+	for _, m := range c.Modules {
+		if strings.Trim(m.Path, "/") == "" {
+			add("module path is empty")
+			continue
+		}
+		m.Path = cleanModulePath(m.Path)
+		if m.Path != path.Clean(m.Path) {
+			add("module path %q has empty, . or .. segments", m.Path)
+			continue
+		}
+		if paths[m.Path] {
+			add("duplicate module path %q", m.Path)
+		}
+		paths[m.Path] = true
+		if m.VCS != "" && !validVCS(m.VCS) {
+			add("module %q has unknown VCS %q", m.Path, m.VCS)
+		}
+		if subdir := strings.Trim(m.Subdir, "/"); subdir != "" && !strings.HasSuffix(m.Path, "/"+subdir) {
+			add("module %q doesn't end with its subdir %q", m.Path, subdir)
+		}
+		switch {
+		case m.RepoURL != "":
+			if err := validateURL(m.RepoURL); err != nil {
+				add("module %q has malformed repo URL: %w", m.Path, err)
+			}
+		case c.VCSURL == "":
+			add("module %q has no repo URL and VCS URL is missing", m.Path)
+		}
+		if err := validateDocsURL(m.DocsURL); err != nil {
+			add("module %q %w", m.Path, err)
+		}
+		if m.Forge != "" && !m.Forge.valid() {
+			add("module %q has unknown forge %q", m.Path, m.Forge)
+		}
+		if m.State != "" && !m.State.valid() {
+			add("module %q has unknown state %q", m.Path, m.State)
+		}
+		if m.SourceDir != "" {
+			info, err := os.Stat(m.SourceDir)
+			switch {
+			case err != nil:
+				add("module %q source dir stat error: %w", m.Path, err)
+			case !info.IsDir():
+				add("module %q source dir is not a directory", m.Path)
+			}
+		}
+		if c.StaticDir != "" {
+			staticURL := c.StaticURL
+			if staticURL == "" {
+				staticURL = defaultStaticURL
+			}
+			if strings.HasPrefix(m.Path+"/", staticURL) {
+				add("module %q is shadowed by static URL %q", m.Path, staticURL)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,259 @@
+package vanity_test
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config vanity.Config
+		errs   []string
+	}{
+		{
+			name: "zero value",
+		},
+		{
+			name: "valid",
+			config: vanity.Config{
+				Domain:    "kkn.fi",
+				VCS:       "hg",
+				VCSURL:    "https://hg.kkn.fi/kare",
+				DocsURL:   vanity.DocsForgeTree,
+				Forge:     vanity.ForgeGitLab,
+				StaticDir: "testdata",
+				Modules: []vanity.Module{
+					{Path: "/x", RepoURL: "https://gitlab.com/kare/x", VCS: "git", State: vanity.StateDeprecated},
+					{Path: "/local", RepoURL: "file:///srv/git/local.git"},
+				},
+			},
+		},
+		{
+			name: "all problems",
+			config: vanity.Config{
+				Domain:      "https://kkn.fi",
+				VCS:         "cvs",
+				VCSURL:      "github.com/kare",
+				DocsURL:     "https://pkg.go.dev/{path}",
+				Forge:       "bitbucket",
+				CacheMaxAge: -1,
+				StaticURL:   "/files/",
+				Modules: []vanity.Module{
+					{Path: "/"},
+					{Path: "/x", RepoURL: "gitlab.com/kare/x", VCS: "darcs", State: "archived"},
+					{Path: "x/", DocsURL: "https://{importPath"},
+				},
+			},
+			errs: []string{
+				`vanity: domain "https://kkn.fi" is not a hostname`,
+				`vanity: unknown VCS "cvs"`,
+				`vanity: malformed VCS URL: "github.com/kare" is not an absolute URL`,
+				`vanity: docs URL "https://pkg.go.dev/{path}" has unknown placeholder {path}`,
+				`vanity: unknown forge "bitbucket"`,
+				`vanity: negative cache max age -1ns`,
+				`vanity: static URL "/files/" without static dir`,
+				`vanity: module path is empty`,
+				`vanity: module "/x" has unknown VCS "darcs"`,
+				`vanity: module "/x" has malformed repo URL: "gitlab.com/kare/x" is not an absolute URL`,
+				`vanity: module "/x" has unknown state "archived"`,
+				`vanity: duplicate module path "/x"`,
+				`vanity: module "/x" docs URL "https://{importPath" has an unterminated placeholder`,
+			},
+		},
+		{
+			name: "missing VCS URL",
+			config: vanity.Config{
+				IndexPageHandler: http.NotFoundHandler(),
+				IndexTemplate:    vanity.DefaultIndexTemplate,
+				Modules: []vanity.Module{
+					{Path: "/x"},
+					{Path: "/y", RepoURL: "https://gitlab.com/kare/y"},
+					{Path: "/z"},
+				},
+			},
+			errs: []string{
+				"vanity: both index page handler and index template are set",
+				`vanity: module "/x" has no repo URL and VCS URL is missing`,
+				`vanity: module "/z" has no repo URL and VCS URL is missing`,
+			},
+		},
+		{
+			name: "every module has a repo URL",
+			config: vanity.Config{
+				Modules: []vanity.Module{
+					{Path: "/y", RepoURL: "https://gitlab.com/kare/y"},
+				},
+			},
+		},
+		{
+			name: "dot segments",
+			config: vanity.Config{
+				VCSURL: "https://github.com/kare",
+				Modules: []vanity.Module{
+					{Path: "/payments/.."},
+					{Path: "/payments/../infra"},
+					{Path: "/x/./y"},
+					{Path: "/x//y"},
+				},
+			},
+			errs: []string{
+				`vanity: module path "/payments/.." has empty, . or .. segments`,
+				`vanity: module path "/payments/../infra" has empty, . or .. segments`,
+				`vanity: module path "/x/./y" has empty, . or .. segments`,
+				`vanity: module path "/x//y" has empty, . or .. segments`,
+			},
+		},
+		{
+			name: "subdir",
+			config: vanity.Config{
+				VCSURL: "https://github.com/kare",
+				Modules: []vanity.Module{
+					{Path: "/project/sub", Subdir: "sub/"},
+					{Path: "/other", Subdir: "sub"},
+				},
+			},
+			errs: []string{
+				`vanity: module "/other" doesn't end with its subdir "sub"`,
+			},
+		},
+		{
+			name: "static dir",
+			config: vanity.Config{
+				VCSURL:    "https://github.com/kare",
+				StaticDir: "testdata/index.html",
+				Modules: []vanity.Module{
+					{Path: "/x", SourceDir: "testdata/missing"},
+				},
+			},
+			errs: []string{
+				"vanity: static dir path is not a directory",
+				`vanity: module "/x" source dir stat error: stat testdata/missing: no such file or directory`,
+			},
+		},
+		{
+			name: "module shadowed by static URL",
+			config: vanity.Config{
+				VCSURL:    "https://github.com/kare",
+				StaticDir: "testdata",
+				StaticURL: "/x/",
+				Modules:   []vanity.Module{{Path: "/x"}},
+			},
+			errs: []string{
+				`vanity: module "/x" is shadowed by static URL "/x/"`,
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.config.Validate()
+			if len(test.errs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			expected := strings.Join(test.errs, "\n")
+			if err.Error() != expected {
+				t.Errorf("expected errors:\n%v\nbut got:\n%v", expected, err)
+			}
+		})
+	}
+}
+
+func TestNewHandlerNotReadable(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	_, err := vanity.NewHandler(vanity.Config{
+		StaticDir: dir,
+		VCS:       "cvs",
+	})
+	if !errors.Is(err, vanity.ErrNotReadable) {
+		t.Errorf("expected %v, but got %v", vanity.ErrNotReadable, err)
+	}
+	if err == nil || !strings.Contains(err.Error(), `unknown VCS "cvs"`) {
+		t.Errorf("expected VCS error to be reported, but got %v", err)
+	}
+}
+
+func TestNewHandlerWithOptionsAggregatesErrors(t *testing.T) {
+	_, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.DefaultForge("bitbucket"),
+		vanity.CacheMaxAge(-1),
+		vanity.Modules(vanity.Module{Path: "/x"}, vanity.Module{Path: "/x"}),
+	)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	expected := `vanity: unknown forge "bitbucket"
+vanity: negative cache max age -1ns
+vanity: duplicate module path "/x"`
+	if err.Error() != expected {
+		t.Errorf("expected errors:\n%v\nbut got:\n%v", expected, err)
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	srv, err := vanity.NewHandler(vanity.Config{
+		Domain: "kkn.fi",
+		VCSURL: "https://github.com/kare",
+		Modules: []vanity.Module{
+			{Path: "x/", RepoURL: "https://gitlab.com/kare/x", VCS: "hg"},
+		},
+		Log: log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		contains string
+	}{
+		{
+			name:     "vcs url",
+			path:     "/vanity?go-get=1",
+			contains: `<meta name="go-import" content="kkn.fi/vanity git https://github.com/kare/vanity">`,
+		},
+		{
+			name:     "module",
+			path:     "/x/sub?go-get=1",
+			contains: `<meta name="go-import" content="kkn.fi/x hg https://gitlab.com/kare/x">`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("expected response status 200, but got %v", res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if !strings.Contains(string(body), test.contains) {
+				t.Errorf("expected body to contain %q, but got:\n%s", test.contains, body)
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package vanity
 import (
 	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 type (
@@ -15,10 +18,24 @@ type (
 		vcs              string
 		vcsURL           string
 		domain           string
-		moduleServerURL  string
+		docsURL          string
+		forge            Forge
+		branch           string
+		modules          []Module
 		static           *staticDir
 		indexPageHandler http.Handler
+		landingPage      *template.Template
+		indexTemplate    *template.Template
+		indexData        interface{}
+		docsTemplate     *template.Template
+		versionsMu       sync.Mutex
+		versionsCache    map[string]*cachedVersions
+		searchTemplate   *template.Template
+		searchMu         sync.Mutex
+		search           *searchIndex
 		robotsTxt        string
+		cacheMaxAge      time.Duration
+		config           Config
 	}
 	staticDir struct {
 		uRLPath string
@@ -33,12 +50,8 @@ type (
 	}
 )
 
-const (
-	// mPkgGoDev is the default module server by Google.
-	mPkgGoDev = "https://pkg.go.dev/"
-	// mGitHub is not an actual module server, but a source code repository.
-	mGitHub = "https://github.com/"
-)
+// mGitHub is not an actual module server, but a source code repository.
+const mGitHub = "https://github.com/"
 
 // DefaultIndexPageHandler serves given indexFilePath over HTTP via http.ServeFile(w, r, name).
 func DefaultIndexPageHandler(indexFilePath string) http.Handler {
@@ -54,53 +67,83 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.cacheMaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.cacheMaxAge.Seconds())))
+	}
+
 	if h.static != nil && strings.HasPrefix(r.URL.Path, h.static.uRLPath) {
 		h.static.fs.ServeHTTP(w, r)
 		return
 	}
 
-	if r.URL.Path == "/" || r.URL.Path == "" {
-		if h.indexPageHandler != nil {
-			h.indexPageHandler.ServeHTTP(w, r)
-			return
-		}
-		DefaultIndexPageHandler(h.static.path+"/index.html").ServeHTTP(w, r)
+	domain := r.Host
+	if h.domain != "" {
+		domain = h.domain
 	}
 
-	if r.URL.Path == "/robots.txt" {
-		_, _ = fmt.Fprintf(w, "%s", h.robotsTxt)
+	if r.URL.Path == "/" || r.URL.Path == "" {
+		h.serveIndex(w, r, domain)
 		return
 	}
 
-	domain := r.Host
-	if h.domain != "" {
-		domain = h.domain
+	if r.URL.Path == robotsTxtPath {
+		h.serveRobotsTxt(w, domain)
+		return
 	}
+	if r.URL.Path == sitemapPath {
+		h.serveSitemap(w, domain)
+		return
+	}
+
 	// Respond to Go tool with vcs info meta tag
 	if r.FormValue("go-get") == "1" {
-		path := r.URL.Path
-		const cmd = "/cmd/"
-		if strings.HasPrefix(r.URL.Path, cmd) {
-			path = path[len(cmd):]
-		}
-		vcsroot := h.vcsURL
-		shortPath := pathComponents(path)
-		stripSubPackagesFromPath := len(shortPath) > 0
-		if stripSubPackagesFromPath {
-			vcsroot = h.vcsURL + shortPath[0]
-		}
+		h.render(w, goGetTemplate, h.resolveRequest(r, domain, r.URL.Path).page())
+		return
+	}
 
-		importRoot := strings.TrimSuffix(domain+r.URL.Path, "/")
-		metaTag := fmt.Sprintf(`<meta name="go-import" content="%v %v %v">`, importRoot, h.vcs, vcsroot)
-		if _, err := w.Write([]byte(metaTag)); err != nil {
-			h.log.Printf("vanity: i/o error writing go tool http response: %v", err)
-		}
+	if h.serveAPI(w, r, domain) {
+		return
+	}
+	if r.URL.Path == searchPath {
+		h.serveSearch(w, r, domain)
+		return
+	}
+	if r.URL.Path == feedPath {
+		h.serveFeed(w, r, domain, nil)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, feedPath) {
+		h.serveFeed(w, r, domain, h.resolveRequest(r, domain, strings.TrimSuffix(r.URL.Path, feedPath)))
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, badgeSuffix) {
+		h.serveBadge(w, r, h.resolveRequest(r, domain, strings.TrimSuffix(r.URL.Path, badgeSuffix)))
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, versionsSuffix) {
+		h.serveVersions(w, r, h.resolveRequest(r, domain, strings.TrimSuffix(r.URL.Path, versionsSuffix)))
+		return
+	}
+
+	t := h.resolveRequest(r, domain, r.URL.Path)
+	if t.version == "latest" {
+		h.serveLatest(w, r, t)
+		return
+	}
+	if acceptsJSON(r) {
+		h.writeJSON(w, http.StatusOK, h.modulePage(t))
+		return
+	}
+	if h.serveDocs(w, r, t) {
+		return
+	}
+	if h.landingPage != nil {
+		h.render(w, h.landingPage, h.modulePage(t))
 		return
 	}
 
 	// Redirect browsers to Go module site.
-	url := h.browserURL(domain, r.URL.Path)
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	http.Redirect(w, r, t.browserURL(), http.StatusTemporaryRedirect)
 }
 
 func pathComponents(path string) []string {
@@ -110,24 +153,6 @@ func pathComponents(path string) []string {
 	return strings.FieldsFunc(path, f)
 }
 
-func (h *handler) browserURL(domain, path string) string {
-	// domain = kkn.fi
-	// path = /foo/bar
-
-	if strings.HasPrefix(h.moduleServerURL, mGitHub) {
-		pkg := path
-		components := pathComponents(pkg)
-		return stripSuffixSlash(h.moduleServerURL) + "/" + components[len(components)-1]
-	}
-	switch h.moduleServerURL {
-	case mPkgGoDev:
-		fallthrough
-	default:
-		pkg := path
-		return fmt.Sprintf("%v%v%v", mPkgGoDev, domain, pkg)
-	}
-}
-
 func stripSuffixSlash(s string) string {
 	if strings.HasSuffix(s, "/") {
 		return s[0 : len(s)-1]
@@ -140,17 +165,91 @@ func stripSuffixSlash(s string) string {
 // be configured by providing options. VCS repository is git by default. VCS
 // can be set with VCS(). Configurable Logger defaults to os.Stderr. Logger can
 // be configured with SetLogger(). Module server URL is https://pkg.go.dev/ and
-// it can be configured via ModuleServerURL() func.  VCSURL() func must be used
+// it can be configured via DocsURL() or ModuleServerURL() func. Import paths
+// can be mapped to specific repositories with Modules(). VCSURL() func must be used
 // to set VCS repository URL (such as https://github.com/kare/).
+//
+// Options are applied to a Config, which is validated and passed to
+// NewHandler(). The returned error reports all problems of the
+// configuration.
 func NewHandlerWithOptions(opts ...Option) (http.Handler, error) {
-	v := &handler{
-		log:             log.New(os.Stderr, "", log.LstdFlags),
-		vcs:             "git",
-		moduleServerURL: mPkgGoDev,
+	c, err := optionsConfig(opts...)
+	if err != nil {
+		return nil, err
 	}
+	return NewHandler(c)
+}
+
+// optionsConfig returns the configuration of opts without validating it.
+func optionsConfig(opts ...Option) (Config, error) {
+	v := &handler{}
 	for _, option := range opts {
 		if err := option(v); err != nil {
-			return nil, err
+			return Config{}, err
+		}
+	}
+	return v.config, nil
+}
+
+// NewHandler returns a handler configured by c. The configuration is checked
+// with Config.Validate() and all problems are returned joined.
+func NewHandler(c Config) (http.Handler, error) {
+	if err := c.Validate(); err != nil {
+		return nil, &validationError{err}
+	}
+	v := &handler{
+		log:              c.Log,
+		vcs:              c.VCS,
+		domain:           c.Domain,
+		docsURL:          c.DocsURL,
+		forge:            c.Forge,
+		branch:           strings.Trim(c.Branch, "/"),
+		indexPageHandler: c.IndexPageHandler,
+		landingPage:      c.LandingPage,
+		indexTemplate:    c.IndexTemplate,
+		indexData:        c.IndexData,
+		docsTemplate:     c.DocsTemplate,
+		searchTemplate:   c.SearchTemplate,
+		robotsTxt:        c.RobotsTxt,
+		cacheMaxAge:      c.CacheMaxAge,
+		config:           c,
+	}
+	if v.log == nil {
+		v.log = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	if v.vcs == "" {
+		v.vcs = "git"
+	}
+	if c.VCSURL != "" {
+		v.vcsURL = addSuffixSlash(c.VCSURL)
+	}
+	if v.docsURL == "" {
+		v.docsURL = DocsPkgGoDev
+	}
+	if v.forge == "" {
+		v.forge = ForgeGitHub
+	}
+	if v.branch == "" {
+		v.branch = defaultBranch
+	}
+	if v.searchTemplate == nil {
+		v.searchTemplate = DefaultSearchTemplate
+	}
+	for _, m := range c.Modules {
+		m.Path = cleanModulePath(m.Path)
+		m.Branch = strings.Trim(m.Branch, "/")
+		m.Subdir = strings.Trim(m.Subdir, "/")
+		v.modules = append(v.modules, m)
+	}
+	if c.StaticDir != "" {
+		urlPath := c.StaticURL
+		if urlPath == "" {
+			urlPath = defaultStaticURL
+		}
+		v.static = &staticDir{
+			path:    c.StaticDir,
+			uRLPath: urlPath,
+			fs:      http.StripPrefix(urlPath, http.FileServer(http.Dir(c.StaticDir))),
 		}
 	}
 	return v, nil
@@ -160,7 +259,7 @@ func NewHandlerWithOptions(opts ...Option) (http.Handler, error) {
 func VCS(vcs string) Option {
 	return func(h http.Handler) error {
 		v := h.(*handler)
-		v.vcs = vcs
+		v.config.VCS = vcs
 		return nil
 	}
 }
@@ -176,7 +275,7 @@ func addSuffixSlash(s string) string {
 func VCSURL(vcsURL string) Option {
 	return func(h http.Handler) error {
 		v := h.(*handler)
-		v.vcsURL = addSuffixSlash(vcsURL)
+		v.config.VCSURL = vcsURL
 		return nil
 	}
 }
@@ -186,29 +285,48 @@ func VCSURL(vcsURL string) Option {
 func Domain(domain string) Option {
 	return func(h http.Handler) error {
 		v := h.(*handler)
-		v.domain = domain
+		v.config.Domain = domain
 		return nil
 	}
 }
 
-// Log sets the logger used by vanity package's error logger.
-func Log(l Logger) Option {
+// CacheMaxAge sets the time clients and proxies may cache responses with a
+// Cache-Control header. Responses have no Cache-Control header by default,
+// except badges which are cached for an hour.
+func CacheMaxAge(maxAge time.Duration) Option {
 	return func(h http.Handler) error {
 		v := h.(*handler)
-		v.log = l
+		v.config.CacheMaxAge = maxAge
 		return nil
 	}
 }
 
-// ModuleServerURL sets Go module server address for browser redirect.
-func ModuleServerURL(moduleServerURL string) Option {
+// Log sets the logger used by vanity package's error logger.
+func Log(l Logger) Option {
 	return func(h http.Handler) error {
 		v := h.(*handler)
-		v.moduleServerURL = addSuffixSlash(moduleServerURL)
+		v.config.Log = l
 		return nil
 	}
 }
 
+// ModuleServerURL sets Go module server address for browser redirect. A
+// GitHub URL such as https://github.com/kare/ redirects browsers to the
+// package directory in the repository tree view instead. ModuleServerURL is a
+// shorthand for DocsURL().
+func ModuleServerURL(moduleServerURL string) Option {
+	switch {
+	case moduleServerURL == "":
+		return DocsURL(DocsPkgGoDev)
+	case strings.HasPrefix(moduleServerURL, mGitHub):
+		return DocsURL(addSuffixSlash(moduleServerURL) + "{repoName}{tree}")
+	default:
+		return DocsURL(DocsPkgsite(moduleServerURL))
+	}
+}
+
+// ErrNotReadable is returned by NewHandler() when the static directory is not
+// readable by everyone.
 var ErrNotReadable = errors.New("vanity: static dir path directory is not readable")
 
 // StaticDir serves a file system directory over HTTP. Given path is the local
@@ -216,39 +334,8 @@ var ErrNotReadable = errors.New("vanity: static dir path directory is not readab
 func StaticDir(path, URLPath string) Option {
 	return func(h http.Handler) error {
 		v := h.(*handler)
-		info, err := os.Stat(path)
-		if err != nil {
-			return fmt.Errorf("vanity: static dir path stat error: %w", err)
-		}
-		if !info.IsDir() {
-			return errors.New("vanity: static dir path is not a directory")
-		}
-		const (
-			read = 4
-			// write = 2
-			// exec = 1
-		)
-		const (
-			readUser  = read << 6
-			readGroup = read << 3
-			readOther = read << 0
-		)
-		if info.Mode().Perm()&readOther == 0 {
-			return ErrNotReadable
-		}
-		if info.Mode().Perm()&readGroup == 0 {
-			return ErrNotReadable
-		}
-		if info.Mode().Perm()&readUser == 0 {
-			return ErrNotReadable
-		}
-		dir := http.Dir(path)
-		server := http.FileServer(dir)
-		v.static = &staticDir{
-			path:    path,
-			uRLPath: URLPath,
-			fs:      http.StripPrefix(URLPath, server),
-		}
+		v.config.StaticDir = path
+		v.config.StaticURL = URLPath
 		return nil
 	}
 }
@@ -257,7 +344,7 @@ func StaticDir(path, URLPath string) Option {
 func IndexPageHandler(index http.Handler) Option {
 	return func(h http.Handler) error {
 		v := h.(*handler)
-		v.indexPageHandler = index
+		v.config.IndexPageHandler = index
 		return nil
 	}
 }
@@ -269,14 +356,17 @@ Allow: /.static/*$
 Disallow: /`
 
 // RobotsTxt takes in a value for robots.txt. If value is empty, the value of
-// `DefaultRobotsTxt` is used
+// `DefaultRobotsTxt` is used. Without RobotsTxt, robots.txt is generated from
+// the configured modules: index, landing, documentation and feed pages of
+// public modules are allowed and everything else is disallowed. The generated
+// robots.txt refers to the sitemap at /sitemap.xml.
 func RobotsTxt(robotsTxt string) Option {
 	return func(h http.Handler) error {
 		v := h.(*handler)
 		if robotsTxt != "" {
-			v.robotsTxt = robotsTxt
+			v.config.RobotsTxt = robotsTxt
 		} else {
-			v.robotsTxt = DefaultRobotsTxt
+			v.config.RobotsTxt = DefaultRobotsTxt
 		}
 		return nil
 	}
@@ -350,6 +350,51 @@ func TestGoTool(t *testing.T) {
 	}
 }
 
+func TestGoToolSubdir(t *testing.T) {
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(vanity.Module{Path: "/project/sub", Subdir: "sub"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		goImport string
+		goSource string
+	}{
+		{
+			path:     "/project/sub?go-get=1",
+			goImport: "kkn.fi/project git https://github.com/kare/project",
+			goSource: "kkn.fi/project https://github.com/kare/project https://github.com/kare/project/tree/main{/dir} https://github.com/kare/project/blob/main{/dir}/{file}#L{line}",
+		},
+		{
+			path:     "/project/sub/pkg?go-get=1",
+			goImport: "kkn.fi/project git https://github.com/kare/project",
+			goSource: "kkn.fi/project https://github.com/kare/project https://github.com/kare/project/tree/main{/dir} https://github.com/kare/project/blob/main{/dir}/{file}#L{line}",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv.ServeHTTP(rec, req)
+			body, _ := io.ReadAll(rec.Result().Body)
+			for _, expected := range []string{
+				fmt.Sprintf(`<meta name="go-import" content="%v">`, test.goImport),
+				fmt.Sprintf(`<meta name="go-source" content="%v">`, test.goSource),
+			} {
+				if !strings.Contains(string(body), expected) {
+					t.Errorf("expecting url '%v' body to contain html meta tag:\n%v, but got:\n%v", test.path, expected, string(body))
+				}
+			}
+		})
+	}
+}
+
 func TestStaticDir(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,239 @@
+package vanity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is a released version of a module.
+type Version struct {
+	// Version is the semantic version, such as v1.2.3.
+	Version string `json:"version"`
+	// Tag is the name of the git tag, such as sub/v1.2.3.
+	Tag string `json:"tag"`
+	// Time is the tagger date of annotated tags and the committer date of
+	// lightweight tags.
+	Time time.Time `json:"time"`
+	// Message is the tag message of annotated tags and the commit message of
+	// lightweight tags.
+	Message string `json:"message,omitempty"`
+}
+
+// versionsCacheTTL is the time versions read from a repository are cached.
+const versionsCacheTTL = time.Minute
+
+// versionsSuffix is the path suffix of the JSON version listing of a module.
+const versionsSuffix = "/@versions"
+
+// cachedVersions is a cache entry of the versions of a module. The entry is
+// filled in by the lookup reading the repository, which closes ready when
+// done. Concurrent lookups of the module wait for it.
+type cachedVersions struct {
+	ready    chan struct{}
+	versions []Version
+	err      error
+	expires  time.Time
+}
+
+// RepoVersions reads the versions of a module from the semver tags of a
+// local git repository at repoDir, which is typically a bare repository.
+// Tags of a module in a subdirectory of the repository are prefixed with the
+// subdirectory, such as sub/v1.2.0. Only versions matching the major version
+// suffix of modulePath, such as /v2, are returned. Versions are ordered from
+// the highest to the lowest by semver precedence. Git is killed if ctx is
+// done.
+func RepoVersions(ctx context.Context, repoDir, modulePath, subdir string) ([]Version, error) {
+	const format = "%(refname:strip=2)%00%(creatordate:iso-strict)%00%(contents)%00"
+	out, err := runGit(ctx, repoDir, "for-each-ref", "--format", format, "refs/tags")
+	if err != nil {
+		return nil, fmt.Errorf("vanity: error listing tags of %v: %w", repoDir, err)
+	}
+	prefix := ""
+	if subdir = strings.Trim(subdir, "/"); subdir != "" {
+		prefix = subdir + "/"
+	}
+	major := pathMajor(modulePath)
+	var versions []Version
+	for _, record := range strings.Split(string(out), "\x00\n") {
+		fields := strings.SplitN(record, "\x00", 3)
+		if len(fields) != 3 || !strings.HasPrefix(fields[0], prefix) {
+			continue
+		}
+		v := strings.TrimPrefix(fields[0], prefix)
+		s, ok := parseSemver(v)
+		if !ok || s.build != "" || !majorMatches(s.major, major) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("vanity: error parsing date of tag %v: %w", fields[0], err)
+		}
+		versions = append(versions, Version{
+			Version: v,
+			Tag:     fields[0],
+			Time:    t,
+			Message: strings.TrimSpace(fields[2]),
+		})
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compareSemver(versions[i].Version, versions[j].Version) > 0
+	})
+	return versions, nil
+}
+
+// pathMajor returns the major version number of a module path with a major
+// version suffix, such as 2 for kkn.fi/project/v2, or an empty string.
+func pathMajor(modulePath string) string {
+	last := path.Base(modulePath)
+	if len(last) < 2 || last[0] != 'v' || !isNumber(last[1:]) || last == "v0" || last == "v1" {
+		return ""
+	}
+	return last[1:]
+}
+
+// majorMatches reports whether a version with the given major version
+// belongs to a module whose path has the given major version suffix.
+func majorMatches(major, pathMajor string) bool {
+	if pathMajor == "" {
+		return major == "0" || major == "1"
+	}
+	return major == pathMajor
+}
+
+// latestVersion returns the version go get would choose as the latest: the
+// highest release, or the highest prerelease if there are no releases, or
+// the highest pseudo-version. Versions must be ordered from the highest to
+// the lowest.
+func latestVersion(versions []Version) (Version, bool) {
+	for _, v := range versions {
+		if s, _ := parseSemver(v.Version); s.prerelease == "" {
+			return v, true
+		}
+	}
+	for _, v := range versions {
+		if !isPseudoVersion(v.Version) {
+			return v, true
+		}
+	}
+	if len(versions) > 0 {
+		return versions[0], true
+	}
+	return Version{}, false
+}
+
+// errNoRepoDir is returned for modules without a local repository.
+var errNoRepoDir = errors.New("vanity: module has no local repository")
+
+// versions returns the cached versions of the module of t. The repository is
+// read without holding the cache lock, so only lookups of the same module
+// wait for it. Errors are not cached.
+func (h *handler) versions(t *target) ([]Version, error) {
+	if t.module == nil || t.module.RepoDir == "" {
+		return nil, errNoRepoDir
+	}
+	h.versionsMu.Lock()
+	c, ok := h.versionsCache[t.module.Path]
+	if ok {
+		select {
+		case <-c.ready:
+			if c.err == nil && time.Now().Before(c.expires) {
+				h.versionsMu.Unlock()
+				return c.versions, nil
+			}
+		default:
+			h.versionsMu.Unlock()
+			<-c.ready
+			return c.versions, c.err
+		}
+	}
+	c = &cachedVersions{ready: make(chan struct{})}
+	if h.versionsCache == nil {
+		h.versionsCache = make(map[string]*cachedVersions)
+	}
+	h.versionsCache[t.module.Path] = c
+	h.versionsMu.Unlock()
+
+	// The lookup is shared by concurrent requests, so it isn't canceled
+	// with the request that started it.
+	c.versions, c.err = RepoVersions(context.Background(), t.module.RepoDir, t.moduleRoot, t.module.Subdir)
+	c.expires = time.Now().Add(versionsCacheTTL)
+	close(c.ready)
+	return c.versions, c.err
+}
+
+// versionList is the JSON response of the version listing.
+type versionList struct {
+	Module   string    `json:"module"`
+	Latest   string    `json:"latest,omitempty"`
+	Versions []Version `json:"versions"`
+}
+
+// serveVersions responds with the versions of the module of t as JSON.
+func (h *handler) serveVersions(w http.ResponseWriter, r *http.Request, t *target) {
+	versions, err := h.versions(t)
+	if errors.Is(err, errNoRepoDir) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.log.Printf("%v", err)
+		status := http.StatusInternalServerError
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	list := versionList{
+		Module:   t.moduleRoot,
+		Versions: versions,
+	}
+	if list.Versions == nil {
+		list.Versions = []Version{}
+	}
+	if latest, ok := latestVersion(versions); ok {
+		list.Latest = latest.Version
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		h.log.Printf("vanity: i/o error writing versions response: %v", err)
+	}
+}
+
+// serveLatest redirects browsers requesting the latest version of the module
+// of t to the highest version.
+func (h *handler) serveLatest(w http.ResponseWriter, r *http.Request, t *target) {
+	versions, err := h.versions(t)
+	if err != nil && !errors.Is(err, errNoRepoDir) {
+		h.log.Printf("%v", err)
+		status := http.StatusInternalServerError
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	latest, ok := latestVersion(versions)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	t.version = latest.Version
+	url := t.browserURL()
+	if h.landingPage != nil {
+		url = t.versionedPath()
+	}
+	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+}
+
+// versionedPath returns the request path of t with its version, such as
+// /project@v1.2.3/sub/pkg.
+func (t *target) versionedPath() string {
+	root := strings.TrimPrefix(t.moduleRoot, t.domain)
+	p := root + "@" + t.version
+	if t.subPath != "" {
+		p += "/" + t.subPath
+	}
+	return p
+}
@@ -0,0 +1,423 @@
+package vanity_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"kkn.fi/vanity"
+)
+
+// testTag is a git tag created by gitRepo.
+type testTag struct {
+	name string
+	// date is the committer date of the tagged commit and the tagger date of
+	// annotated tags.
+	date string
+	// message makes the tag annotated.
+	message string
+}
+
+// git runs git in dir with a fixed identity and dates.
+func git(t *testing.T, dir, date string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Vanity",
+		"GIT_AUTHOR_EMAIL=vanity@kkn.fi",
+		"GIT_COMMITTER_NAME=Vanity",
+		"GIT_COMMITTER_EMAIL=vanity@kkn.fi",
+		"GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_DATE="+date,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"HOME="+dir,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+// gitRepo creates a bare git repository containing the given files, with a
+// commit for each tag. It returns the path of the bare repository.
+func gitRepo(t *testing.T, files map[string]string, tags ...testTag) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	work := t.TempDir()
+	const initDate = "2020-01-01T00:00:00Z"
+	git(t, work, initDate, "init", "-q", "-b", "main")
+	for name, content := range files {
+		p := filepath.Join(work, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(t, work, initDate, "add", "-A")
+	git(t, work, initDate, "commit", "-q", "--allow-empty", "-m", "initial commit")
+	for _, tag := range tags {
+		git(t, work, tag.date, "commit", "-q", "--allow-empty", "-m", "release "+tag.name)
+		if tag.message != "" {
+			git(t, work, tag.date, "tag", "-a", "-m", tag.message, tag.name)
+		} else {
+			git(t, work, tag.date, "tag", tag.name)
+		}
+	}
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	git(t, work, initDate, "clone", "-q", "--bare", work, bare)
+	return bare
+}
+
+func TestRepoVersionsIntegration(t *testing.T) {
+	integrationTest(t)
+	repo := gitRepo(t, nil,
+		testTag{name: "v1.0.0", date: "2021-01-01T00:00:00Z", message: "First release."},
+		testTag{name: "v1.10.0", date: "2021-03-01T00:00:00Z"},
+		testTag{name: "v1.2.0", date: "2021-02-01T00:00:00Z"},
+		testTag{name: "v1.11.0-rc.1", date: "2021-04-01T00:00:00Z"},
+		testTag{name: "v1.11.0-rc.2", date: "2021-04-02T00:00:00Z"},
+		testTag{name: "v1.11.0-beta", date: "2021-03-15T00:00:00Z"},
+		testTag{name: "v1.11.1-0.20210401000000-abcdefabcdef", date: "2021-04-03T00:00:00Z"},
+		testTag{name: "v2.0.0", date: "2022-01-01T00:00:00Z"},
+		testTag{name: "v2.1.0", date: "2022-02-01T00:00:00Z"},
+		testTag{name: "sub/v0.1.0", date: "2021-05-01T00:00:00Z"},
+		testTag{name: "sub/v0.2.0", date: "2021-06-01T00:00:00Z", message: "Sub release.\n\nWith details."},
+		testTag{name: "v1.3", date: "2021-07-01T00:00:00Z"},
+		testTag{name: "v01.0.0", date: "2021-07-01T00:00:00Z"},
+		testTag{name: "v1.4.0+build", date: "2021-07-01T00:00:00Z"},
+		testTag{name: "release-1", date: "2021-07-01T00:00:00Z"},
+	)
+	tests := []struct {
+		name       string
+		modulePath string
+		subdir     string
+		versions   []string
+	}{
+		{
+			name:       "major version 1",
+			modulePath: "kkn.fi/project",
+			versions: []string{
+				"v1.11.1-0.20210401000000-abcdefabcdef",
+				"v1.11.0-rc.2",
+				"v1.11.0-rc.1",
+				"v1.11.0-beta",
+				"v1.10.0",
+				"v1.2.0",
+				"v1.0.0",
+			},
+		},
+		{
+			name:       "major version suffix",
+			modulePath: "kkn.fi/project/v2",
+			versions:   []string{"v2.1.0", "v2.0.0"},
+		},
+		{
+			name:       "subdirectory module",
+			modulePath: "kkn.fi/project/sub",
+			subdir:     "/sub/",
+			versions:   []string{"v0.2.0", "v0.1.0"},
+		},
+		{
+			name:       "subdirectory module without tags",
+			modulePath: "kkn.fi/project/other",
+			subdir:     "other",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			versions, err := vanity.RepoVersions(context.Background(), repo, test.modulePath, test.subdir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range versions {
+				got = append(got, v.Version)
+			}
+			if !reflect.DeepEqual(got, test.versions) {
+				t.Errorf("expecting versions\n%v, but got\n%v", test.versions, got)
+			}
+		})
+	}
+
+	versions, err := vanity.RepoVersions(context.Background(), repo, "kkn.fi/project/sub", "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := versions[0]
+	if v.Tag != "sub/v0.2.0" || v.Message != "Sub release.\n\nWith details." || v.Time.Format("2006-01-02") != "2021-06-01" {
+		t.Errorf("unexpected version %+v", v)
+	}
+}
+
+func TestRepoVersionsNotFoundIntegration(t *testing.T) {
+	integrationTest(t)
+	_, err := vanity.RepoVersions(context.Background(), filepath.Join(t.TempDir(), "missing.git"), "kkn.fi/project", "")
+	if err == nil || !strings.Contains(err.Error(), "git for-each-ref: exit status") {
+		t.Errorf("expecting git for-each-ref error, but got %v", err)
+	}
+}
+
+func TestRepoVersionsCanceledIntegration(t *testing.T) {
+	integrationTest(t)
+	repo := gitRepo(t, map[string]string{"go.mod": "module kkn.fi/project\n"}, testTag{name: "v1.0.0"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := vanity.RepoVersions(ctx, repo, "kkn.fi/project", ""); err == nil {
+		t.Error("expecting error of canceled context, but got nil")
+	}
+}
+
+func TestVersionsIntegration(t *testing.T) {
+	integrationTest(t)
+	release := gitRepo(t, nil,
+		testTag{name: "v1.0.0", date: "2021-01-01T00:00:00Z", message: "First release."},
+		testTag{name: "v1.1.0", date: "2021-02-01T00:00:00Z"},
+		testTag{name: "v1.2.0-rc.1", date: "2021-03-01T00:00:00Z"},
+	)
+	prerelease := gitRepo(t, nil,
+		testTag{name: "v0.1.0-alpha", date: "2021-01-01T00:00:00Z"},
+		testTag{name: "v0.1.1-0.20210201000000-abcdefabcdef", date: "2021-02-01T00:00:00Z"},
+	)
+	empty := gitRepo(t, nil)
+	modules := []vanity.Module{
+		{Path: "/release", RepoDir: release},
+		{Path: "/prerelease", RepoDir: prerelease},
+		{Path: "/empty", RepoDir: empty},
+	}
+	tests := []struct {
+		name     string
+		path     string
+		landing  bool
+		status   int
+		location string
+		body     string
+	}{
+		{
+			name:     "latest release",
+			path:     "/release@latest/sub",
+			status:   http.StatusTemporaryRedirect,
+			location: "https://pkg.go.dev/kkn.fi/release/sub@v1.1.0",
+		},
+		{
+			name:     "latest release with landing page",
+			path:     "/release@latest/sub",
+			landing:  true,
+			status:   http.StatusTemporaryRedirect,
+			location: "/release@v1.1.0/sub",
+		},
+		{
+			name:     "latest prerelease",
+			path:     "/prerelease@latest",
+			status:   http.StatusTemporaryRedirect,
+			location: "https://pkg.go.dev/kkn.fi/prerelease@v0.1.0-alpha",
+		},
+		{
+			name:   "latest without versions",
+			path:   "/empty@latest",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "latest without repository",
+			path:   "/vanity@latest",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "version list",
+			path:   "/release/@versions",
+			status: http.StatusOK,
+			body:   `{"module":"kkn.fi/release","latest":"v1.1.0","versions":[{"version":"v1.2.0-rc.1","tag":"v1.2.0-rc.1","time":"2021-03-01T00:00:00Z","message":"release v1.2.0-rc.1"},{"version":"v1.1.0","tag":"v1.1.0","time":"2021-02-01T00:00:00Z","message":"release v1.1.0"},{"version":"v1.0.0","tag":"v1.0.0","time":"2021-01-01T00:00:00Z","message":"First release."}]}` + "\n",
+		},
+		{
+			name:   "empty version list",
+			path:   "/empty/@versions",
+			status: http.StatusOK,
+			body:   `{"module":"kkn.fi/empty","versions":[]}` + "\n",
+		},
+		{
+			name:   "version list without repository",
+			path:   "/vanity/@versions",
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "landing page shows latest version",
+			path:    "/release",
+			landing: true,
+			status:  http.StatusOK,
+			body:    `<li><a href="https://kkn.fi/release@v1.1.0">v1.1.0</a> <time datetime="2021-02-01T00:00:00Z">2021-02-01</time></li>`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			opts := []vanity.Option{
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(modules...),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			}
+			if test.landing {
+				opts = append(opts, vanity.LandingPage(nil))
+			}
+			srv, err := vanity.NewHandlerWithOptions(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != test.status {
+				t.Errorf("expected response status %v, but got %v", test.status, res.StatusCode)
+			}
+			if location := res.Header.Get("Location"); location != test.location {
+				t.Errorf("expecting redirect to\n%v, but got\n%v", test.location, location)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if test.body == "" {
+				return
+			}
+			if strings.HasPrefix(test.body, "{") {
+				if !json.Valid(body) || string(body) != test.body {
+					t.Errorf("expecting body\n%v, but got\n%s", test.body, body)
+				}
+				return
+			}
+			if !strings.Contains(string(body), test.body) {
+				t.Errorf("expecting\n%v be contained in\n%s", test.body, body)
+			}
+		})
+	}
+}
+
+func TestBadgeIntegration(t *testing.T) {
+	integrationTest(t)
+	repo := gitRepo(t,
+		map[string]string{
+			"go.mod":     "module kkn.fi/project\n\ngo 1.22\n",
+			"sub/go.mod": "module kkn.fi/project/sub\n\ngo 1.23.1\n",
+		},
+		testTag{name: "v1.0.0", date: "2021-01-01T00:00:00Z"},
+		testTag{name: "sub/v0.3.0", date: "2021-01-01T00:00:00Z"},
+	)
+	tests := []struct {
+		path  string
+		label string
+	}{
+		{path: "/project/badge.svg?type=version", label: "version: v1.0.0"},
+		{path: "/project/badge.svg?type=go", label: "go: 1.22"},
+		{path: "/project/sub/badge.svg?type=version", label: "version: v0.3.0"},
+		{path: "/project/sub/badge.svg?type=go", label: "go: 1.23.1"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+			srv, err := vanity.NewHandlerWithOptions(
+				vanity.VCSURL("https://github.com/kare"),
+				vanity.Modules(
+					vanity.Module{Path: "/project", RepoDir: repo},
+					vanity.Module{Path: "/project/sub", RepoDir: repo, Subdir: "sub"},
+				),
+				vanity.Log(log.New(io.Discard, "", 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.ServeHTTP(rec, req)
+			body, _ := io.ReadAll(rec.Result().Body)
+			if expected := "<title>" + test.label + "</title>"; !strings.Contains(string(body), expected) {
+				t.Errorf("expecting\n%v be contained in\n%s", expected, body)
+			}
+		})
+	}
+}
+
+func TestBadgeGoModMissingIntegration(t *testing.T) {
+	integrationTest(t)
+	repo := gitRepo(t, nil, testTag{name: "v1.0.0", date: "2021-01-01T00:00:00Z"})
+	var logs strings.Builder
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(vanity.Module{Path: "/project", RepoDir: repo}),
+		vanity.Log(log.New(&logs, "", 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, addr+"/project/badge.svg?type=go", nil)
+	srv.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Result().Body)
+	if expected := "<title>go: unknown</title>"; !strings.Contains(string(body), expected) {
+		t.Errorf("expecting\n%v be contained in\n%s", expected, body)
+	}
+	if expected := "vanity: error reading go.mod of kkn.fi/project: git show:"; !strings.Contains(logs.String(), expected) {
+		t.Errorf("expected log to contain %q, but got %q", expected, logs.String())
+	}
+}
+
+func TestVersionsConcurrentIntegration(t *testing.T) {
+	integrationTest(t)
+	x := gitRepo(t, nil, testTag{name: "v1.0.0", date: "2021-01-01T00:00:00Z"})
+	y := gitRepo(t, nil, testTag{name: "v0.1.0", date: "2021-02-01T00:00:00Z"})
+	srv, err := vanity.NewHandlerWithOptions(
+		vanity.VCSURL("https://github.com/kare"),
+		vanity.Modules(
+			vanity.Module{Path: "/x", RepoDir: x},
+			vanity.Module{Path: "/y", RepoDir: y},
+		),
+		vanity.Log(log.New(io.Discard, "", 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path   string
+		latest string
+	}{
+		{"/x/@versions", "v1.0.0"},
+		{"/y/@versions", "v0.1.0"},
+	}
+	// Concurrent lookups of a module share one read of its repository.
+	for i := 0; i < 8; i++ {
+		for _, test := range tests {
+			test := test
+			t.Run(test.path, func(t *testing.T) {
+				t.Parallel()
+
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, addr+test.path, nil)
+				srv.ServeHTTP(rec, req)
+				var list struct {
+					Latest string `json:"latest"`
+				}
+				if err := json.NewDecoder(rec.Result().Body).Decode(&list); err != nil {
+					t.Fatal(err)
+				}
+				if list.Latest != test.latest {
+					t.Errorf("expected latest version %v, but got %v", test.latest, list.Latest)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package vanity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlNode is a scalar or a mapping of a YAML document. Only the subset of
+// YAML used by configuration files is supported: block mappings of plain or
+// quoted scalars and comments.
+type yamlNode struct {
+	line  int
+	value string
+	// keys of a mapping in document order.
+	keys    []string
+	mapping map[string]*yamlNode
+}
+
+// yamlLine is a key and value line of a YAML document.
+type yamlLine struct {
+	num    int
+	indent int
+	key    string
+	value  string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+// parseYAML parses a YAML document whose root is a mapping.
+func parseYAML(data []byte) (*yamlNode, error) {
+	var p yamlParser
+	for i, raw := range strings.Split(string(data), "\n") {
+		num := i + 1
+		text := strings.TrimRight(stripYAMLComment(strings.TrimSuffix(raw, "\r")), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", num)
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			return nil, fmt.Errorf("line %d: sequences are not supported", num)
+		}
+		key, value, err := splitYAMLKey(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+		p.lines = append(p.lines, yamlLine{
+			num:    num,
+			indent: len(text) - len(trimmed),
+			key:    key,
+			value:  value,
+		})
+	}
+	if len(p.lines) == 0 {
+		return &yamlNode{mapping: make(map[string]*yamlNode)}, nil
+	}
+	root, err := p.mapping(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.i].num)
+	}
+	return root, nil
+}
+
+// mapping parses the block mapping at indent.
+func (p *yamlParser) mapping(indent int) (*yamlNode, error) {
+	node := &yamlNode{
+		line:    p.lines[p.i].num,
+		mapping: make(map[string]*yamlNode),
+	}
+	for p.i < len(p.lines) {
+		l := p.lines[p.i]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		p.i++
+		if _, ok := node.mapping[l.key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, l.key)
+		}
+		child := &yamlNode{line: l.num}
+		switch {
+		case l.value != "":
+			v, err := parseYAMLScalar(l.value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.num, err)
+			}
+			child.value = v
+		case p.i < len(p.lines) && p.lines[p.i].indent > indent:
+			m, err := p.mapping(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			child = m
+		}
+		node.keys = append(node.keys, l.key)
+		node.mapping[l.key] = child
+	}
+	return node, nil
+}
+
+// stripYAMLComment removes a comment from line. A comment starts with # at
+// the beginning of the line or after whitespace outside of quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitYAMLKey splits a mapping line into its key and raw value.
+func splitYAMLKey(line string) (key, value string, err error) {
+	if line[0] == '"' || line[0] == '\'' {
+		end := closingQuote(line)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		key, err = parseYAMLScalar(line[:end+1])
+		if err != nil {
+			return "", "", err
+		}
+		rest := line[end+1:]
+		if rest != ":" && !strings.HasPrefix(rest, ": ") {
+			return "", "", fmt.Errorf("expected a colon after key %q", key)
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+	i := strings.Index(line, ": ")
+	switch {
+	case i >= 0:
+		key, value = line[:i], strings.TrimSpace(line[i+2:])
+	case strings.HasSuffix(line, ":"):
+		key = line[:len(line)-1]
+	default:
+		return "", "", fmt.Errorf("expected a key and a value: %q", line)
+	}
+	return strings.TrimSpace(key), value, nil
+}
+
+// closingQuote returns the index of the quote closing the quoted scalar at
+// the beginning of s, or -1.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case q == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseYAMLScalar returns the value of a plain or quoted scalar.
+func parseYAMLScalar(s string) (string, error) {
+	switch s[0] {
+	case '"':
+		if closingQuote(s) != len(s)-1 {
+			return "", fmt.Errorf("invalid double quoted scalar %s", s)
+		}
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid double quoted scalar %s", s)
+		}
+		return v, nil
+	case '\'':
+		if closingQuote(s) != len(s)-1 {
+			return "", fmt.Errorf("invalid single quoted scalar %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case '[', '{', '|', '>', '&', '*', '!':
+		return "", fmt.Errorf("unsupported value %s", s)
+	}
+	return s, nil
+}